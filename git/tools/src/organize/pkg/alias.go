@@ -0,0 +1,220 @@
+package organize
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/kevinburke/ssh_config"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// remoteAliases expands the aliases a remote url may be written with into the url git actually connects to,
+// so its host, owner, and name can be parsed.
+type remoteAliases struct {
+	// urls are the url.<base>.insteadOf rules from the global and then the repo git config.
+	urls []*config.URL
+
+	// ssh is the user's ssh config, or nil if they do not have one.
+	ssh *ssh_config.Config
+}
+
+// loadUserAliases loads the insteadOf rules of the global git config and the user's ssh config from fs. If
+// either can not be loaded, the error is returned along with the aliases from the other, since aliases only
+// help organize repos and should not stop them being organized.
+func loadUserAliases(fs billy.Filesystem) (remoteAliases, error) {
+	var aliases remoteAliases
+	var errs []error
+
+	if globalConfig, err := loadGlobalConfig(fs); err != nil {
+		errs = append(errs, fmt.Errorf("could not load global git config: %w", err))
+	} else if globalConfig != nil {
+		aliases.urls = maps.Values(globalConfig.URLs)
+	}
+
+	if ssh, err := loadSSHConfig(fs); err != nil {
+		errs = append(errs, fmt.Errorf("could not load ssh config: %w", err))
+	} else {
+		aliases.ssh = ssh
+	}
+
+	return aliases, errors.Join(errs...)
+}
+
+// loadGlobalConfig loads the first of the global git config files which exists in fs, or returns nil if
+// none do.
+func loadGlobalConfig(fs billy.Filesystem) (*config.Config, error) {
+	paths, err := config.Paths(config.GlobalScope)
+	if err != nil {
+		return nil, nil
+	}
+
+	for _, p := range paths {
+		f, err := fs.Open(p)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return config.ReadConfig(f)
+	}
+
+	return nil, nil
+}
+
+// loadSSHConfig loads ~/.ssh/config from fs, or returns nil if it does not exist.
+func loadSSHConfig(fs billy.Filesystem) (*ssh_config.Config, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, nil
+	}
+
+	f, err := fs.Open(filepath.Join(home, ".ssh", "config"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ssh_config.Decode(f)
+}
+
+// forRepo returns aliases with the insteadOf rules of repo's git config added, which take precedence over
+// the user's.
+func (aliases remoteAliases) forRepo(repo *git.Repository) (remoteAliases, error) {
+	repoConfig, err := repo.Config()
+	if err != nil {
+		return remoteAliases{}, fmt.Errorf("could not load repo git config: %w", err)
+	}
+
+	aliases.urls = append(slices.Clip(aliases.urls), maps.Values(repoConfig.URLs)...)
+
+	return aliases, nil
+}
+
+// insteadOf applies the longest matching insteadOf rule to u. Like git, rules from the repo's config take
+// precedence over global rules of the same length.
+func (aliases remoteAliases) insteadOf(u string) string {
+	var longest *config.URL
+	for _, rule := range aliases.urls {
+		if rule.InsteadOf != "" && strings.HasPrefix(u, rule.InsteadOf) && (longest == nil || len(rule.InsteadOf) >= len(longest.InsteadOf)) {
+			longest = rule
+		}
+	}
+
+	if longest == nil {
+		return u
+	}
+
+	return longest.ApplyInsteadOf(u)
+}
+
+// hostName returns the HostName the ssh config gives host, or host if it is not an alias.
+func (aliases remoteAliases) hostName(host string) string {
+	if aliases.ssh == nil {
+		return host
+	}
+
+	hostName, err := aliases.ssh.Get(host, "HostName")
+	if err != nil || hostName == "" {
+		return host
+	}
+
+	return strings.ReplaceAll(hostName, "%h", host)
+}
+
+// resolve returns u with any insteadOf rules applied, and the host of ssh urls replaced with the HostName
+// the ssh config gives it.
+func (aliases remoteAliases) resolve(u string) string {
+	u = aliases.insteadOf(u)
+
+	if isSCPLike(u) {
+		host, p, _ := strings.Cut(u, ":")
+		user, host, found := strings.Cut(host, "@")
+		if !found {
+			return aliases.hostName(user) + ":" + p
+		}
+
+		return user + "@" + aliases.hostName(host) + ":" + p
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "ssh" {
+		return u
+	}
+
+	if hostName := aliases.hostName(parsed.Hostname()); hostName != parsed.Hostname() {
+		if port := parsed.Port(); port != "" {
+			hostName += ":" + port
+		}
+
+		parsed.Host = hostName
+		return parsed.String()
+	}
+
+	return u
+}
+
+// resolveURLs returns urls with each url resolved.
+func (aliases remoteAliases) resolveURLs(urls []string) []string {
+	resolved := make([]string, len(urls))
+	for i, u := range urls {
+		resolved[i] = aliases.resolve(u)
+	}
+
+	return resolved
+}
+
+// resolvedRemotes returns the remotes of repo as they will be once rewritten by config, with their urls
+// resolved using aliases and repo's own insteadOf rules, so repos are planned by the remotes they will end up with. The remotes are only for determining
+// where the repo is organized, and are not saved to the repo's config.
+func resolvedRemotes(config Config, aliases remoteAliases, repo *git.Repository) ([]*git.Remote, error) {
+	remotes, err := repo.Remotes()
+	if err != nil || len(remotes) == 0 {
+		return remotes, err
+	}
+
+	aliases, err = aliases.forRepo(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	rewrites := config.RewriteRemotes
+	urls := make(map[string][]string, len(remotes))
+	for _, remote := range remotes {
+		rewritten := make([]string, len(remote.Config().URLs))
+		for i, u := range remote.Config().URLs {
+			rewritten[i] = rewrites.RewriteURL(u)
+		}
+
+		urls[remote.Config().Name] = aliases.resolveURLs(rewritten)
+	}
+
+	// conflicting renames are skipped when the remotes are rewritten
+	renames := rewrites.renames(urls)
+	if len(conflictingRenames(urls, renames)) != 0 {
+		renames = nil
+	}
+
+	for i, remote := range remotes {
+		remoteConfig := *remote.Config()
+		remoteConfig.URLs = urls[remoteConfig.Name]
+		if to, found := renames[remoteConfig.Name]; found {
+			remoteConfig.Name = to
+		}
+
+		remotes[i] = git.NewRemote(repo.Storer, &remoteConfig)
+	}
+
+	return remotes, nil
+}
@@ -0,0 +1,147 @@
+package organize
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// aliasHome sets HOME to a directory with a global git config and ssh config defining aliases.
+func aliasHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	require.NoError(t, os.WriteFile(path.Join(home, ".gitconfig"), []byte(`[url "git@github.com:"]
+	insteadOf = gh:
+[url "https://gitlab.com/"]
+	insteadOf = gl:
+`), 0644))
+
+	require.NoError(t, os.MkdirAll(path.Join(home, ".ssh"), 0755))
+	require.NoError(t, os.WriteFile(path.Join(home, ".ssh", "config"), []byte(`Host github-work
+	HostName github.com
+	User git
+
+Host *.internal
+	HostName %h.example.com
+`), 0644))
+}
+
+func TestResolveAliases(t *testing.T) {
+	aliasHome(t)
+
+	_, repo := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+	cfg, err := repo.Config()
+	require.NoError(t, err)
+	cfg.URLs["git@gitlab.com:"] = &config.URL{Name: "git@gitlab.com:", InsteadOf: "gl:"}
+	require.NoError(t, repo.SetConfig(cfg))
+
+	aliases, err := loadUserAliases(NewOSFilesystem())
+	require.NoError(t, err)
+	aliases, err = aliases.forRepo(repo)
+	require.NoError(t, err)
+
+	for u, expected := range map[string]string{
+		"gh:owner/name.git":                        "git@github.com:owner/name.git",
+		"gl:owner/name.git":                        "git@gitlab.com:owner/name.git",
+		"github-work:owner/name.git":               "github.com:owner/name.git",
+		"git@github-work:owner/name.git":           "git@github.com:owner/name.git",
+		"ssh://git@github-work:2222/owner/name":    "ssh://git@github.com:2222/owner/name",
+		"git@git.internal:owner/name.git":          "git@git.internal.example.com:owner/name.git",
+		"https://github-work/owner/name.git":       "https://github-work/owner/name.git",
+		"git@github.com:originuser/origin.git":     "git@github.com:originuser/origin.git",
+		"https://github.com/mirroruser/mirror.git": "https://github.com/mirroruser/mirror.git",
+	} {
+		assert.Equal(t, expected, aliases.resolve(u), u)
+	}
+}
+
+func TestOrganizeAliases(t *testing.T) {
+	aliasHome(t)
+
+	tempDir := t.TempDir()
+	first, _ := RepoWithRemotes(t, path.Join(tempDir, "first"), []*config.RemoteConfig{{Name: "origin", URLs: []string{"gh:owner/first.git"}}})
+	second, _ := RepoWithRemotes(t, path.Join(tempDir, "second"), []*config.RemoteConfig{{Name: "origin", URLs: []string{"github-work:owner/second.git"}}})
+
+	cfg := NewDefaultConfig()
+	cfg.Destination = path.Join(tempDir, "destination")
+	cfg.HostRoots = map[string]string{"github.com": "github"}
+	cfg.Where = `host == "github.com"`
+
+	report := NewOrganizer(WithConfig(cfg)).OrganizePaths(context.Background(), []string{first, second})
+	require.Equal(t, 2, report.Count(StatusOrganized), report.Entries)
+	assert.DirExists(t, path.Join(cfg.Destination, "github", "owner", "first"))
+	assert.DirExists(t, path.Join(cfg.Destination, "github", "owner", "second"))
+
+	// the remotes themselves are left as they were
+	repo, err := openRepo(NewOSFilesystem(), path.Join(cfg.Destination, "github", "owner", "second"))
+	require.NoError(t, err)
+	remote, err := repo.Remote("origin")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"github-work:owner/second.git"}, remote.Config().URLs)
+}
+
+func TestUnloadableAliases(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	require.NoError(t, os.WriteFile(path.Join(home, ".gitconfig"), []byte(`[url "git@github.com:"]
+	insteadOf = gh:
+`), 0644))
+
+	require.NoError(t, os.MkdirAll(path.Join(home, ".ssh"), 0755))
+	require.NoError(t, os.WriteFile(path.Join(home, ".ssh", "config"), []byte(`Match host github-work
+	HostName github.com
+`), 0644))
+
+	var logs bytes.Buffer
+	o := NewOrganizer(WithLogger(log.New(&logs, "", 0)))
+	assert.Contains(t, logs.String(), "WARNING: ignoring remote aliases: could not load ssh config")
+
+	// the global git config is still used without the ssh config
+	_, repo := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+	aliases, err := o.aliases.forRepo(repo)
+	require.NoError(t, err)
+	assert.Equal(t, "git@github.com:owner/name.git", aliases.resolve("gh:owner/name.git"))
+	assert.Equal(t, "github-work:owner/name.git", aliases.resolve("github-work:owner/name.git"))
+
+	require.NoError(t, os.WriteFile(path.Join(home, ".gitconfig"), []byte("[url \"unterminated"), 0644))
+	require.NoError(t, os.Remove(path.Join(home, ".ssh", "config")))
+
+	logs.Reset()
+	o = NewOrganizer(WithLogger(log.New(&logs, "", 0)))
+	assert.Contains(t, logs.String(), "WARNING: ignoring remote aliases: could not load global git config")
+
+	remotes, err := resolvedRemotes(NewDefaultConfig(), o.aliases, repo)
+	require.NoError(t, err)
+	assert.Equal(t, remoteOrigin.URLs, remotes[0].Config().URLs)
+}
+
+func TestAliasesFilesystem(t *testing.T) {
+	aliasHome(t)
+
+	// aliases are read from the organizer's filesystem rather than the OS home directory
+	fs := memfs.New()
+	assert.Empty(t, NewOrganizer(WithFilesystem(fs)).aliases.urls)
+
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(fs, path.Join(home, ".gitconfig"), []byte(`[url "git@github.com:"]
+	insteadOf = mem:
+`), 0644))
+
+	aliases := NewOrganizer(WithFilesystem(fs)).aliases
+	assert.Equal(t, "git@github.com:owner/name.git", aliases.resolve("mem:owner/name.git"))
+	assert.Equal(t, "gh:owner/name.git", aliases.resolve("gh:owner/name.git"))
+}
@@ -0,0 +1,875 @@
+package organize
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// BackupMetadataFile is the name of the file describing a backup inside of the backup's directory.
+	BackupMetadataFile = "backup.yaml"
+
+	// BackupBundleFile is the name of the git bundle holding every ref of the repo.
+	BackupBundleFile = "repo.bundle"
+
+	// BackupFilesFile is the name of the tarball holding the repo's untracked, ignored, and changed files.
+	BackupFilesFile = "files.tar.gz"
+
+	// BackupIndexFile is the name of the copy of the repo's index.
+	BackupIndexFile = "index"
+)
+
+const bundleSignature = "# v2 git bundle"
+
+// BackupMetadata describes the repo a backup was made from.
+type BackupMetadata struct {
+	Source string    `yaml:"source"`
+	Time   time.Time `yaml:"time"`
+
+	Bare bool `yaml:"bare,omitempty"`
+
+	// Branch is the branch which was checked out in the repo, or empty if HEAD was detached.
+	Branch string `yaml:"branch,omitempty"`
+
+	// Remotes are stored separately since bundles do not include the repo's config.
+	Remotes []ManifestRemote `yaml:"remotes"`
+
+	// Deleted are the files in HEAD or the index which had been deleted from the working tree.
+	Deleted []string `yaml:"deleted,omitempty"`
+}
+
+// Backup is a backup of a repo in the backup directory.
+type Backup struct {
+	Path     string
+	Metadata BackupMetadata
+}
+
+// bundleObjects returns every object reachable from tips, along with the parents of any shallow commits,
+// which are missing from the repo and so are prerequisites of the bundle.
+func bundleObjects(s storer.EncodedObjectStorer, tips []plumbing.Hash, shallow []plumbing.Hash) ([]plumbing.Hash, []plumbing.Hash, error) {
+	var objects, prerequisites []plumbing.Hash
+
+	seen := make(map[plumbing.Hash]bool)
+	pending := slices.Clone(tips)
+
+	for len(pending) != 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+
+		obj, err := object.GetObject(s, hash)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read object %s: %w", hash, err)
+		}
+
+		objects = append(objects, hash)
+
+		switch obj := obj.(type) {
+		case *object.Commit:
+			pending = append(pending, obj.TreeHash)
+
+			if slices.Contains(shallow, hash) {
+				prerequisites = append(prerequisites, obj.ParentHashes...)
+			} else {
+				pending = append(pending, obj.ParentHashes...)
+			}
+		case *object.Tree:
+			for _, entry := range obj.Entries {
+				switch {
+				case entry.Mode == filemode.Submodule:
+					// submodule commits are in another repo
+				case entry.Mode == filemode.Dir:
+					pending = append(pending, entry.Hash)
+				case !seen[entry.Hash]:
+					// blobs are added directly rather than being decoded
+					seen[entry.Hash] = true
+					objects = append(objects, entry.Hash)
+				}
+			}
+		case *object.Tag:
+			pending = append(pending, obj.Target)
+		}
+	}
+
+	return objects, prerequisites, nil
+}
+
+// writeBundle writes every ref of repo to w in the git bundle format, so it can also be restored with `git
+// clone`. Bundles are written with go-git rather than by running `git bundle create --all`, since organize
+// never depends on a git binary being installed and works with repos in any billy filesystem.
+func writeBundle(w io.Writer, repo *git.Repository) error {
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	var names []plumbing.ReferenceName
+	var tips []plumbing.Hash
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		// a detached HEAD is a hash reference, but is added below along with a symbolic one
+		if ref.Type() == plumbing.HashReference && ref.Name() != plumbing.HEAD {
+			names = append(names, ref.Name())
+			tips = append(tips, ref.Hash())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// HEAD is included the same as `git bundle create --all`
+	if head, err := repo.Head(); err == nil {
+		names = append([]plumbing.ReferenceName{plumbing.HEAD}, names...)
+		tips = append([]plumbing.Hash{head.Hash()}, tips...)
+	}
+
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return err
+	}
+
+	objects, prerequisites, err := bundleObjects(repo.Storer, tips, shallow)
+	if err != nil {
+		return err
+	}
+
+	// staged files are not reachable from any ref, but are needed to restore the index
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	included := make(map[plumbing.Hash]bool, len(objects))
+	for _, hash := range objects {
+		included[hash] = true
+	}
+
+	for _, entry := range idx.Entries {
+		if entry.Mode != filemode.Submodule && !included[entry.Hash] && repo.Storer.HasEncodedObject(entry.Hash) == nil {
+			included[entry.Hash] = true
+			objects = append(objects, entry.Hash)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, bundleSignature)
+
+	for _, hash := range prerequisites {
+		fmt.Fprintf(bw, "-%s\n", hash)
+	}
+
+	for i, name := range names {
+		fmt.Fprintf(bw, "%s %s\n", tips[i], name)
+	}
+
+	fmt.Fprintln(bw)
+
+	if _, err := packfile.NewEncoder(bw, repo.Storer, false).Encode(objects, 10); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// readBundleHeader reads the refs from a git bundle, leaving r at the start of the packfile.
+func readBundleHeader(r *bufio.Reader) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	signature, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(signature) != bundleSignature {
+		return nil, fmt.Errorf("unsupported bundle format '%s'", strings.TrimSpace(signature))
+	}
+
+	refs := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return refs, nil
+		}
+
+		// the repo being restored into will not have any prerequisites
+		if strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		hash, name, found := strings.Cut(line, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid bundle ref '%s'", line)
+		}
+
+		refs[plumbing.ReferenceName(name)] = plumbing.NewHash(hash)
+	}
+}
+
+// worktreeHash returns the hash and mode the file at p would have if it were added to the index.
+func worktreeHash(fs billy.Filesystem, p string, info os.FileInfo) (plumbing.Hash, filemode.FileMode, error) {
+	mode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return plumbing.ZeroHash, mode, err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := fs.Readlink(p)
+		if err != nil {
+			return plumbing.ZeroHash, mode, err
+		}
+
+		return plumbing.ComputeHash(plumbing.BlobObject, []byte(link)), mode, nil
+	}
+
+	f, err := fs.Open(p)
+	if err != nil {
+		return plumbing.ZeroHash, mode, err
+	}
+	defer f.Close()
+
+	h := plumbing.NewHasher(plumbing.BlobObject, info.Size())
+	if _, err := io.Copy(h, f); err != nil {
+		return plumbing.ZeroHash, mode, err
+	}
+
+	return h.Sum(), mode, nil
+}
+
+// changedFiles returns the tracked files in the working tree of repo at repoPath which differ from HEAD or its
+// index, and the files in HEAD or its index which have been deleted from the working tree.
+func changedFiles(fs billy.Filesystem, repoPath string, repo *git.Repository) (map[string]bool, []string, error) {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	committed := make(map[string]*object.File)
+	if head, err := repo.Head(); err == nil {
+		commit, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		files, err := commit.Files()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = files.ForEach(func(f *object.File) error {
+			committed[f.Name] = f
+			return nil
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, nil, err
+	}
+
+	changed := make(map[string]bool)
+	var deleted []string
+
+	for _, entry := range idx.Entries {
+		if entry.Mode == filemode.Submodule {
+			continue
+		}
+
+		info, err := fs.Lstat(path.Join(repoPath, entry.Name))
+		if os.IsNotExist(err) {
+			deleted = append(deleted, entry.Name)
+			continue
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		if f, found := committed[entry.Name]; !found || f.Hash != entry.Hash || f.Mode != entry.Mode {
+			changed[entry.Name] = true
+			continue
+		}
+
+		hash, mode, err := worktreeHash(fs, path.Join(repoPath, entry.Name), info)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if hash != entry.Hash || mode != entry.Mode {
+			changed[entry.Name] = true
+		}
+	}
+
+	// files removed from the index would otherwise be checked out again from HEAD
+	for name := range committed {
+		if _, err := idx.Entry(name); err == nil {
+			continue
+		}
+
+		if _, err := fs.Lstat(path.Join(repoPath, name)); os.IsNotExist(err) {
+			deleted = append(deleted, name)
+		} else if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	slices.Sort(deleted)
+	return changed, deleted, nil
+}
+
+// writeIndex writes the index of repo to w.
+func writeIndex(w io.Writer, repo *git.Repository) error {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	// the entries of v4 indexes are decoded in full, so they can be written as v3
+	if idx.Version > index.EncodeVersionSupported {
+		idx.Version = index.EncodeVersionSupported
+	}
+
+	return index.NewEncoder(w).Encode(idx)
+}
+
+// writeWorktreeFiles writes every file in the working tree of repo at repoPath which is not in its index, or
+// is in changed, to w as a gzipped tarball.
+func writeWorktreeFiles(ctx context.Context, fs billy.Filesystem, w io.Writer, repoPath string, repo *git.Repository, changed map[string]bool) error {
+	idx, err := repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	tracked := make(map[string]bool, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		tracked[entry.Name] = !changed[entry.Name]
+	}
+
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	err = util.Walk(fs, repoPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if p == repoPath {
+			return nil
+		}
+
+		rel, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+
+		// submodules are tracked as a single entry
+		if info.Name() == ".git" || tracked[rel] {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = fs.Readlink(p); err != nil {
+				return err
+			}
+		} else if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := fs.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, contextReader{ctx: ctx, r: f})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// extractFiles extracts a gzipped tarball written by writeWorktreeFiles into dir, replacing any files which
+// already exist.
+func extractFiles(fs billy.Filesystem, r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("'%s' is outside of the repo", header.Name)
+		}
+
+		target := path.Join(dir, name)
+
+		if header.Typeflag != tar.TypeDir {
+			if info, err := fs.Lstat(target); err == nil && !info.IsDir() {
+				if err := fs.Remove(target); err != nil {
+					return err
+				}
+			}
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = fs.MkdirAll(target, header.FileInfo().Mode().Perm()|0700)
+		case tar.TypeSymlink:
+			err = fs.Symlink(header.Linkname, target)
+		case tar.TypeReg:
+			var f billy.File
+			if f, err = fs.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, header.FileInfo().Mode().Perm()); err == nil {
+				_, err = io.Copy(f, tr)
+				if closeErr := f.Close(); err == nil {
+					err = closeErr
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		if fs, ok := fs.(changeFilesystem); ok && header.Typeflag == tar.TypeReg {
+			_ = fs.Lchtimes(target, header.ModTime, header.ModTime)
+		}
+	}
+}
+
+// writeBackupFile creates p in fs and writes its contents with fn.
+func writeBackupFile(fs billy.Filesystem, p string, fn func(io.Writer) error) (err error) {
+	f, err := fs.Create(p)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	bw := bufio.NewWriter(f)
+	if err := fn(bw); err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// backup writes a bundle of the repo at repoPath, its index, and a tarball of its untracked, ignored, and
+// changed files into the backup directory, then prunes old backups. It returns the path of the backup, or an empty string if
+// backups are disabled.
+func (o *Organizer) backup(ctx context.Context, repoPath string) (string, error) {
+	if o.config.BackupPath() == "" {
+		return "", nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	entry, err := o.manifestRepo(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("could not read repo '%s' to back up: %w", repoPath, err)
+	}
+
+	repo, err := openRepo(o.fs, repoPath)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	metadata := BackupMetadata{
+		Source:  absPath(o.fs, repoPath),
+		Time:    now,
+		Bare:    entry.Bare,
+		Remotes: entry.Remotes,
+	}
+
+	// unlike the manifest, the branch is recorded even if it does not have any commits yet
+	if head, err := repo.Storer.Reference(plumbing.HEAD); err == nil && head.Type() == plumbing.SymbolicReference {
+		metadata.Branch = head.Target().Short()
+	}
+
+	backupPath := path.Join(o.config.BackupPath(), fmt.Sprintf("%s-%s", path.Base(repoPath), now.UTC().Format("20060102T150405.000000000Z")))
+
+	err = func() error {
+		if err := o.fs.MkdirAll(backupPath, 0755); err != nil {
+			return err
+		}
+
+		err := writeBackupFile(o.fs, path.Join(backupPath, BackupBundleFile), func(w io.Writer) error {
+			return writeBundle(w, repo)
+		})
+		if err != nil {
+			return fmt.Errorf("could not write bundle: %w", err)
+		}
+
+		if !metadata.Bare {
+			changed, deleted, err := changedFiles(o.fs, repoPath, repo)
+			if err != nil {
+				return fmt.Errorf("could not find changed files: %w", err)
+			}
+			metadata.Deleted = deleted
+
+			err = writeBackupFile(o.fs, path.Join(backupPath, BackupIndexFile), func(w io.Writer) error {
+				return writeIndex(w, repo)
+			})
+			if err != nil {
+				return fmt.Errorf("could not write index: %w", err)
+			}
+
+			err = writeBackupFile(o.fs, path.Join(backupPath, BackupFilesFile), func(w io.Writer) error {
+				return writeWorktreeFiles(ctx, o.fs, w, repoPath, repo, changed)
+			})
+			if err != nil {
+				return fmt.Errorf("could not write untracked files: %w", err)
+			}
+		}
+
+		data, err := yaml.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+
+		return util.WriteFile(o.fs, path.Join(backupPath, BackupMetadataFile), data, 0644)
+	}()
+	if err != nil {
+		// partial backups would be mistaken for complete ones
+		if removeErr := util.RemoveAll(o.fs, backupPath); removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+
+		return "", fmt.Errorf("could not back up repo '%s': %w", repoPath, err)
+	}
+
+	if err := o.pruneBackups(now); err != nil {
+		o.logger.Printf("ERROR: could not prune backups: %s", err)
+	}
+
+	return backupPath, nil
+}
+
+// ReadBackup reads the backup at backupPath.
+func (o *Organizer) ReadBackup(backupPath string) (Backup, error) {
+	backup := Backup{Path: backupPath}
+
+	data, err := util.ReadFile(o.fs, path.Join(backupPath, BackupMetadataFile))
+	if err != nil {
+		return backup, err
+	}
+
+	if err := yaml.Unmarshal(data, &backup.Metadata); err != nil {
+		return backup, fmt.Errorf("could not parse metadata for backup '%s': %w", backupPath, err)
+	}
+
+	return backup, nil
+}
+
+// ListBackups returns every backup in the backup directory, newest first.
+func (o *Organizer) ListBackups() ([]Backup, error) {
+	if o.config.BackupPath() == "" {
+		return nil, nil
+	}
+
+	items, err := o.fs.ReadDir(o.config.BackupPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var backups []Backup
+	for _, item := range items {
+		if !item.IsDir() {
+			continue
+		}
+
+		backup, err := o.ReadBackup(path.Join(o.config.BackupPath(), item.Name()))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		backups = append(backups, backup)
+	}
+
+	slices.SortStableFunc(backups, func(a, b Backup) bool {
+		return a.Metadata.Time.After(b.Metadata.Time)
+	})
+
+	return backups, nil
+}
+
+// pruneBackups removes backups beyond the newest Config.BackupKeep of each repo, and backups older than
+// Config.BackupMaxAge.
+func (o *Organizer) pruneBackups(now time.Time) error {
+	if o.config.BackupKeep <= 0 && o.config.BackupMaxAge <= 0 {
+		return nil
+	}
+
+	backups, err := o.ListBackups()
+	if err != nil {
+		return err
+	}
+
+	kept := make(map[string]int)
+
+	var errs []error
+	for _, backup := range backups {
+		expired := o.config.BackupMaxAge > 0 && now.Sub(backup.Metadata.Time) > o.config.BackupMaxAge
+		if !expired && (o.config.BackupKeep <= 0 || kept[backup.Metadata.Source] < o.config.BackupKeep) {
+			kept[backup.Metadata.Source]++
+			continue
+		}
+
+		o.logger.Printf("pruning backup '%s'", backup.Path)
+		if err := util.RemoveAll(o.fs, backup.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// importBundle adds the objects and refs in the bundle at bundlePath to repo, and returns the refs.
+func importBundle(fs billy.Filesystem, repo *git.Repository, bundlePath string) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	f, err := fs.Open(bundlePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	refs, err := readBundleHeader(r)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bundle '%s': %w", bundlePath, err)
+	}
+
+	// repos without any commits have nothing to import
+	if len(refs) == 0 {
+		return refs, nil
+	}
+
+	if err := packfile.UpdateObjectStorage(repo.Storer, r); err != nil {
+		return nil, fmt.Errorf("could not import bundle '%s': %w", bundlePath, err)
+	}
+
+	for name, hash := range refs {
+		if name == plumbing.HEAD {
+			continue
+		}
+
+		if err := repo.Storer.SetReference(plumbing.NewHashReference(name, hash)); err != nil {
+			return nil, err
+		}
+	}
+
+	return refs, nil
+}
+
+// RestoreBackup recreates the repo in the backup at backupPath, including its index and untracked, ignored,
+// and changed files, at target. If target is empty, the repo is restored where it was backed up from, or
+// beside it if it is still there. It returns the path of the restored repo. Existing directories are never
+// overwritten.
+func (o *Organizer) RestoreBackup(_ context.Context, backupPath string, target string) (string, error) {
+	backup, err := o.ReadBackup(backupPath)
+	if err != nil {
+		return "", fmt.Errorf("could not read backup '%s': %w", backupPath, err)
+	}
+
+	if target == "" {
+		target = backup.Metadata.Source
+
+		// organizing copies repos, so the original is usually still in place
+		if o.exists(target) {
+			target = fmt.Sprintf("%s.restored-%s", target, backup.Metadata.Time.UTC().Format("20060102T150405Z"))
+		}
+	}
+
+	if _, err := o.fs.Lstat(target); err == nil {
+		return "", fmt.Errorf("'%s' already exists", target)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := o.restoreBackup(backup, target); err != nil {
+		// leave nothing half restored behind so the restore can be retried
+		if removeErr := util.RemoveAll(o.fs, target); removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+
+		return "", fmt.Errorf("could not restore backup '%s': %w", backupPath, err)
+	}
+
+	return target, nil
+}
+
+func (o *Organizer) restoreBackup(backup Backup, target string) error {
+	worktree, err := o.fs.Chroot(target)
+	if err != nil {
+		return err
+	}
+
+	dot := worktree
+	if backup.Metadata.Bare {
+		worktree = nil
+	} else if dot, err = worktree.Chroot(".git"); err != nil {
+		return err
+	}
+
+	repo, err := git.Init(filesystem.NewStorage(dot, cache.NewObjectLRUDefault()), worktree)
+	if err != nil {
+		return err
+	}
+
+	refs, err := importBundle(o.fs, repo, path.Join(backup.Path, BackupBundleFile))
+	if err != nil {
+		return err
+	}
+
+	var head *plumbing.Reference
+	if backup.Metadata.Branch != "" {
+		head = plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(backup.Metadata.Branch))
+	} else if hash, found := refs[plumbing.HEAD]; found {
+		head = plumbing.NewHashReference(plumbing.HEAD, hash)
+	}
+
+	if head != nil {
+		if err := repo.Storer.SetReference(head); err != nil {
+			return err
+		}
+	}
+
+	for _, remote := range backup.Metadata.Remotes {
+		if _, err := repo.CreateRemote(&config.RemoteConfig{Name: remote.Name, URLs: remote.URLs}); err != nil {
+			return fmt.Errorf("could not create remote '%s': %w", remote.Name, err)
+		}
+	}
+
+	if backup.Metadata.Bare {
+		return nil
+	}
+
+	if hash, found := refs[plumbing.HEAD]; found {
+		wt, err := repo.Worktree()
+		if err != nil {
+			return err
+		}
+
+		if err := wt.Reset(&git.ResetOptions{Commit: hash, Mode: git.HardReset}); err != nil {
+			return fmt.Errorf("could not check out '%s': %w", hash, err)
+		}
+	}
+
+	if err := restoreIndex(o.fs, repo, path.Join(backup.Path, BackupIndexFile)); err != nil {
+		return fmt.Errorf("could not restore index: %w", err)
+	}
+
+	f, err := o.fs.Open(path.Join(backup.Path, BackupFilesFile))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := extractFiles(o.fs, f, target); err != nil {
+		return fmt.Errorf("could not restore untracked files: %w", err)
+	}
+
+	for _, name := range backup.Metadata.Deleted {
+		if err := o.fs.Remove(path.Join(target, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreIndex replaces the index of repo with the one at indexPath, if the backup has one.
+func restoreIndex(fs billy.Filesystem, repo *git.Repository, indexPath string) error {
+	f, err := fs.Open(indexPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var idx index.Index
+	if err := index.NewDecoder(bufio.NewReader(f)).Decode(&idx); err != nil {
+		return err
+	}
+
+	return repo.Storer.SetIndex(&idx)
+}
@@ -0,0 +1,249 @@
+package organize
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBackup(t *testing.T) {
+	setup := func(t *testing.T) (*Organizer, string, *git.Repository) {
+		tempDir := t.TempDir()
+		repoDir := path.Join(tempDir, "input", "repo")
+
+		repo, err := git.PlainInit(repoDir, false)
+		require.NoError(t, err)
+
+		_, err = repo.CreateRemote(remoteOrigin)
+		require.NoError(t, err)
+
+		commitFile(t, repo, repoDir, ".gitignore", "build/\n")
+		commitFile(t, repo, repoDir, "README.md", "hello")
+
+		head, err := repo.Head()
+		require.NoError(t, err)
+		require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", head.Hash())))
+
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "notes.txt"), []byte("untracked"), 0644))
+		require.NoError(t, os.MkdirAll(path.Join(repoDir, "build"), 0755))
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "build", "out"), []byte("ignored"), 0755))
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.BackupDir = "backups"
+
+		return NewOrganizer(WithConfig(cfg)), repoDir, repo
+	}
+
+	t.Run("OrganizeAndRestore", func(t *testing.T) {
+		o, repoDir, repo := setup(t)
+
+		report := o.OrganizePaths(context.Background(), []string{repoDir})
+		require.Equal(t, 1, report.Count(StatusOrganized), report.Entries)
+
+		backups, err := o.ListBackups()
+		require.NoError(t, err)
+		require.Len(t, backups, 1)
+		assert.Equal(t, repoDir, backups[0].Metadata.Source)
+		assert.Equal(t, "master", backups[0].Metadata.Branch)
+		assert.Equal(t, []ManifestRemote{{Name: "origin", URLs: remoteOrigin.URLs}}, backups[0].Metadata.Remotes)
+		assert.FileExists(t, path.Join(backups[0].Path, BackupBundleFile))
+		assert.FileExists(t, path.Join(backups[0].Path, BackupFilesFile))
+
+		// the backup is never restored over an existing repo, so by default it is restored beside the original
+		_, err = o.RestoreBackup(context.Background(), backups[0].Path, repoDir)
+		require.Error(t, err)
+
+		beside, err := o.RestoreBackup(context.Background(), backups[0].Path, "")
+		require.NoError(t, err)
+		assert.Equal(t, path.Dir(repoDir), path.Dir(beside))
+		assert.NotEqual(t, repoDir, beside)
+		assertFileContents(t, "untracked", path.Join(beside, "notes.txt"))
+
+		target := path.Join(t.TempDir(), "restored")
+		restoredPath, err := o.RestoreBackup(context.Background(), backups[0].Path, target)
+		require.NoError(t, err)
+		assert.Equal(t, target, restoredPath)
+
+		restored, err := git.PlainOpen(target)
+		require.NoError(t, err)
+
+		for _, name := range []plumbing.ReferenceName{"refs/heads/master", "refs/heads/feature"} {
+			expected, err := repo.Reference(name, true)
+			require.NoError(t, err)
+
+			actual, err := restored.Reference(name, true)
+			require.NoError(t, err, name)
+			assert.Equal(t, expected.Hash(), actual.Hash(), name)
+		}
+
+		head, err := restored.Head()
+		require.NoError(t, err)
+		assert.Equal(t, plumbing.Master, head.Name())
+
+		remote, err := restored.Remote("origin")
+		require.NoError(t, err)
+		assert.Equal(t, remoteOrigin.URLs, remote.Config().URLs)
+
+		assertFileContents(t, "hello", path.Join(target, "README.md"))
+		assertFileContents(t, "untracked", path.Join(target, "notes.txt"))
+		assertFileContents(t, "ignored", path.Join(target, "build", "out"))
+
+		info, err := os.Stat(path.Join(target, "build", "out"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+		wt, err := restored.Worktree()
+		require.NoError(t, err)
+
+		status, err := wt.Status()
+		require.NoError(t, err)
+		assert.Equal(t, git.Status{"notes.txt": &git.FileStatus{Staging: git.Untracked, Worktree: git.Untracked}}, status)
+	})
+
+	t.Run("ChangedTrackedFiles", func(t *testing.T) {
+		o, repoDir, repo := setup(t)
+		commitFile(t, repo, repoDir, "removed.txt", "removed")
+		commitFile(t, repo, repoDir, "deleted.txt", "deleted")
+
+		wt, err := repo.Worktree()
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "README.md"), []byte("modified"), 0644))
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "staged.txt"), []byte("staged"), 0644))
+		_, err = wt.Add("staged.txt")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "staged.txt"), []byte("staged and modified"), 0644))
+		_, err = wt.Remove("removed.txt")
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(path.Join(repoDir, "deleted.txt")))
+
+		expected, err := wt.Status()
+		require.NoError(t, err)
+
+		backupPath, err := o.backup(context.Background(), repoDir)
+		require.NoError(t, err)
+
+		target := path.Join(t.TempDir(), "restored")
+		_, err = o.RestoreBackup(context.Background(), backupPath, target)
+		require.NoError(t, err)
+
+		assertFileContents(t, "modified", path.Join(target, "README.md"))
+		assertFileContents(t, "staged and modified", path.Join(target, "staged.txt"))
+		assert.NoFileExists(t, path.Join(target, "removed.txt"))
+		assert.NoFileExists(t, path.Join(target, "deleted.txt"))
+
+		restored, err := git.PlainOpen(target)
+		require.NoError(t, err)
+
+		restoredWorktree, err := restored.Worktree()
+		require.NoError(t, err)
+
+		status, err := restoredWorktree.Status()
+		require.NoError(t, err)
+		assert.Equal(t, expected, status)
+
+		// the staged contents are restored along with the index
+		idx, err := restored.Storer.Index()
+		require.NoError(t, err)
+		entry, err := idx.Entry("staged.txt")
+		require.NoError(t, err)
+		_, err = restored.BlobObject(entry.Hash)
+		assert.NoError(t, err)
+	})
+
+	t.Run("DetachedHead", func(t *testing.T) {
+		o, repoDir, repo := setup(t)
+
+		head, err := repo.Head()
+		require.NoError(t, err)
+		require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, head.Hash())))
+
+		var bundle bytes.Buffer
+		require.NoError(t, writeBundle(&bundle, repo))
+		assert.Equal(t, 1, strings.Count(bundle.String(), " HEAD\n"))
+
+		backupPath, err := o.backup(context.Background(), repoDir)
+		require.NoError(t, err)
+
+		target := path.Join(t.TempDir(), "restored")
+		_, err = o.RestoreBackup(context.Background(), backupPath, target)
+		require.NoError(t, err)
+
+		restored, err := git.PlainOpen(target)
+		require.NoError(t, err)
+
+		restoredHead, err := restored.Head()
+		require.NoError(t, err)
+		assert.Equal(t, plumbing.HEAD, restoredHead.Name())
+		assert.Equal(t, head.Hash(), restoredHead.Hash())
+	})
+
+	t.Run("Bare", func(t *testing.T) {
+		o, _, _ := setup(t)
+		repoDir, _ := BareRepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+
+		backupPath, err := o.backup(context.Background(), repoDir)
+		require.NoError(t, err)
+		assert.NoFileExists(t, path.Join(backupPath, BackupFilesFile))
+
+		target := path.Join(t.TempDir(), "restored.git")
+		_, err = o.RestoreBackup(context.Background(), backupPath, target)
+		require.NoError(t, err)
+
+		restored, err := git.PlainOpen(target)
+		require.NoError(t, err)
+
+		_, err = restored.Worktree()
+		assert.ErrorIs(t, err, git.ErrIsBareRepository)
+	})
+
+	t.Run("Prune", func(t *testing.T) {
+		o, repoDir, _ := setup(t)
+		o.config.BackupKeep = 2
+
+		var backups []string
+		for i := 0; i < 3; i++ {
+			backupPath, err := o.backup(context.Background(), repoDir)
+			require.NoError(t, err)
+			backups = append(backups, backupPath)
+		}
+
+		assert.NoDirExists(t, backups[0])
+		assert.DirExists(t, backups[1])
+		assert.DirExists(t, backups[2])
+
+		// age the remaining backups
+		for _, backupPath := range backups[1:] {
+			backup, err := o.ReadBackup(backupPath)
+			require.NoError(t, err)
+
+			backup.Metadata.Time = backup.Metadata.Time.Add(-48 * time.Hour)
+			data, err := yaml.Marshal(backup.Metadata)
+			require.NoError(t, err)
+			require.NoError(t, util.WriteFile(o.fs, path.Join(backupPath, BackupMetadataFile), data, 0644))
+		}
+
+		o.config.BackupMaxAge = 24 * time.Hour
+
+		latest, err := o.backup(context.Background(), repoDir)
+		require.NoError(t, err)
+
+		remaining, err := o.ListBackups()
+		require.NoError(t, err)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, latest, remaining[0].Path)
+	})
+}
@@ -0,0 +1,101 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/yaml.v3"
+)
+
+// BucketMetadataSuffix is appended to the name of a directory moved into a bucket to get the name of the
+// file describing why it was moved there.
+const BucketMetadataSuffix = ".organize.yaml"
+
+// BucketMetadata describes why a directory was moved into a bucket.
+type BucketMetadata struct {
+	Source string    `yaml:"source"`
+	Reason string    `yaml:"reason"`
+	Time   time.Time `yaml:"time"`
+}
+
+// moveDir moves src to dst, falling back to copying and removing src when they are on different devices.
+func moveDir(fs billy.Filesystem, src string, dst string) error {
+	err := fs.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	// the moved directory is only kept for inspection, so metadata which could not be preserved is ignored
+	if _, err := copyDir(context.Background(), fs, src, dst); err != nil {
+		return removePartial(fs, dst, err)
+	}
+
+	return util.RemoveAll(fs, src)
+}
+
+// MoveToBucket moves dir into bucket next to a metadata file holding reason, and returns the new path of
+// dir. An existing directory in the bucket is never overwritten.
+func MoveToBucket(fs billy.Filesystem, bucket string, dir string, reason string) (string, error) {
+	target := path.Join(bucket, path.Base(dir))
+
+	if _, err := fs.Lstat(target); err == nil {
+		return "", fmt.Errorf("'%s' already exists", target)
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	if err := fs.MkdirAll(bucket, 0755); err != nil {
+		return "", fmt.Errorf("could not create bucket '%s': %w", bucket, err)
+	}
+
+	if err := moveDir(fs, dir, target); err != nil {
+		return "", fmt.Errorf("could not move '%s' to '%s': %w", dir, target, err)
+	}
+
+	if err := writeBucketMetadata(fs, target, dir, reason); err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+// writeBucketMetadata writes the metadata for dir, which was moved from source because of reason.
+func writeBucketMetadata(fs billy.Filesystem, dir string, source string, reason string) error {
+	data, err := yaml.Marshal(BucketMetadata{
+		Source: source,
+		Reason: reason,
+		Time:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := util.WriteFile(fs, dir+BucketMetadataSuffix, data, 0644); err != nil {
+		return fmt.Errorf("could not write metadata for '%s': %w", dir, err)
+	}
+
+	return nil
+}
+
+// ReadBucketMetadata reads the metadata for a directory in a bucket.
+func ReadBucketMetadata(fs billy.Filesystem, dir string) (BucketMetadata, error) {
+	var metadata BucketMetadata
+
+	data, err := util.ReadFile(fs, dir+BucketMetadataSuffix)
+	if err != nil {
+		return metadata, err
+	}
+
+	if err := yaml.Unmarshal(data, &metadata); err != nil {
+		return metadata, fmt.Errorf("could not parse metadata for '%s': %w", dir, err)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,89 @@
+package organize
+
+import (
+	"errors"
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crossDeviceFilesystem can never rename, as if every path was on a different device, and fails to open
+// files named unreadable.
+type crossDeviceFilesystem struct {
+	billy.Filesystem
+}
+
+func (fs crossDeviceFilesystem) Rename(from string, to string) error {
+	return &os.LinkError{Op: "rename", Old: from, New: to, Err: syscall.EXDEV}
+}
+
+func (fs crossDeviceFilesystem) Open(filename string) (billy.File, error) {
+	if path.Base(filename) == "unreadable" {
+		return nil, &os.PathError{Op: "open", Path: filename, Err: errors.New("unreadable")}
+	}
+
+	return fs.Filesystem.Open(filename)
+}
+
+func TestMoveToBucket(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		fs := memfs.New()
+
+		require.NoError(t, util.WriteFile(fs, "/not-a-repo/notes.txt", []byte("some notes"), 0644))
+
+		target, err := MoveToBucket(fs, "/destination/unsorted", "/not-a-repo", "not a git repository")
+		require.NoError(t, err)
+		assert.Equal(t, "/destination/unsorted/not-a-repo", target)
+
+		_, err = fs.Stat("/not-a-repo")
+		assert.True(t, os.IsNotExist(err))
+
+		data, err := util.ReadFile(fs, "/destination/unsorted/not-a-repo/notes.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "some notes", string(data))
+
+		metadata, err := ReadBucketMetadata(fs, target)
+		require.NoError(t, err)
+		assert.Equal(t, "/not-a-repo", metadata.Source)
+		assert.Equal(t, "not a git repository", metadata.Reason)
+		assert.False(t, metadata.Time.IsZero())
+	})
+
+	t.Run("AlreadyExists", func(t *testing.T) {
+		fs := memfs.New()
+
+		require.NoError(t, fs.MkdirAll("/not-a-repo", 0755))
+		require.NoError(t, fs.MkdirAll("/destination/unsorted/not-a-repo", 0755))
+
+		_, err := MoveToBucket(fs, "/destination/unsorted", "/not-a-repo", "not a git repository")
+		require.Error(t, err)
+
+		_, err = fs.Stat("/not-a-repo")
+		assert.NoError(t, err)
+
+		_, err = fs.Stat("/destination/unsorted/not-a-repo" + BucketMetadataSuffix)
+		assert.True(t, os.IsNotExist(err))
+	})
+	t.Run("CopyFailed", func(t *testing.T) {
+		fs := crossDeviceFilesystem{memfs.New()}
+
+		require.NoError(t, util.WriteFile(fs, "/not-a-repo/notes.txt", []byte("some notes"), 0644))
+		require.NoError(t, util.WriteFile(fs, "/not-a-repo/unreadable", []byte("secret"), 0644))
+
+		_, err := MoveToBucket(fs, "/destination/unsorted", "/not-a-repo", "not a git repository")
+		require.Error(t, err)
+
+		_, err = fs.Stat("/not-a-repo/notes.txt")
+		assert.NoError(t, err)
+
+		_, err = fs.Lstat("/destination/unsorted/not-a-repo")
+		assert.True(t, os.IsNotExist(err))
+	})
+}
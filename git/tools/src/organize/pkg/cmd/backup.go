@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	organize "organize/pkg"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+)
+
+func backupCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "backup",
+		Usage:     "manage backups made before repos were organized",
+		UsageText: "organize [arguments] backup command [command arguments]",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "list backups, newest first",
+				UsageText: "organize [arguments] backup list",
+				Action:    backupList,
+			},
+			{
+				Name:      "restore",
+				Usage:     "recreate a repo and its untracked files from a backup",
+				UsageText: "organize [arguments] backup restore [command arguments] backup",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "where to restore the repo, if not specified it is restored where it was backed up from, or beside it if that still exists",
+					},
+				},
+				Action: backupRestore,
+			},
+		},
+	}
+}
+
+func backupList(args *cli.Context) error {
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if config.BackupPath() == "" {
+		return fmt.Errorf("no backup directory was configured")
+	}
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	backups, err := o.ListBackups()
+	if err != nil {
+		return fmt.Errorf("could not list backups: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "BACKUP\tTIME\tSOURCE")
+	for _, backup := range backups {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", backup.Path, backup.Metadata.Time.Format(time.RFC3339), backup.Metadata.Source)
+	}
+
+	return tw.Flush()
+}
+
+func backupRestore(args *cli.Context) error {
+	if args.NArg() != 1 {
+		return fmt.Errorf("expected exactly one backup to restore")
+	}
+
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	// backups can be given by their name in the backup directory
+	backupPath := args.Args().First()
+	if !strings.Contains(backupPath, "/") {
+		if config.BackupPath() == "" {
+			return fmt.Errorf("no backup directory was configured")
+		}
+
+		backupPath = path.Join(config.BackupPath(), backupPath)
+	}
+
+	unlock, err := lockDestination(args.Context, o, args.Bool("wait"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	target, err := o.RestoreBackup(args.Context, backupPath, args.String("to"))
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("restored backup '%s' to '%s'", backupPath, target)
+
+	return nil
+}
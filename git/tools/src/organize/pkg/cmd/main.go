@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	organize "organize/pkg"
 	"os"
+	"os/signal"
 	"path"
+	"strings"
+	"syscall"
+	"time"
 
-	"github.com/go-git/go-git/v5"
+	"github.com/fsnotify/fsnotify"
 	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v3"
 )
 
 var logger *log.Logger
@@ -24,12 +31,21 @@ func newApp() *cli.App {
 		HelpName:    "organize",
 		Description: "organize you flat development directory into some nested subdirectorie reflecting their github owner and name",
 		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    "config",
+				Usage:   "a yaml file to load configuration from, any flags which are set will override the values in the file",
+				Aliases: []string{"c"},
+			},
 			&cli.StringFlag{
 				Name:    "destination",
 				Usage:   "the top level directory where the repos will be organized into",
 				Value:   ".",
 				Aliases: []string{"d"},
 			},
+			&cli.StringFlag{
+				Name:  "bare-destination",
+				Usage: "the top level directory (absolute or relative to destination) where bare repos and mirrors will be organized into, if not specified destination is used",
+			},
 			&cli.StringFlag{
 				Name:    "stage",
 				Usage:   "the directory (absolute or relative to destination) where the repos will be staged before being organized into destination",
@@ -42,14 +58,22 @@ func newApp() *cli.App {
 				Value:   "quarantine",
 				Aliases: []string{"q"},
 			},
+			&cli.StringFlag{
+				Name:  "unsorted",
+				Usage: "the directory (absolute or relative to destination) where non-repos and repos without remotes will be moved, if not specified they are left in place",
+			},
+			&cli.StringFlag{
+				Name:  "broken",
+				Usage: "the directory (absolute or relative to destination) where repos that could not be opened will be moved, if not specified they are left in place",
+			},
 			&cli.StringSliceFlag{
 				Name:    "include-remotes",
-				Usage:   "remotes to include when organizing repos, if not specified all are included",
+				Usage:   "remotes to include when organizing repos as '[name:|url:|host:]pattern', where pattern is a glob or a regex prefixed with 're:', if not specified all are included",
 				Aliases: []string{"i"},
 			},
 			&cli.StringSliceFlag{
 				Name:    "exclude-remotes",
-				Usage:   "remotes to exclude when organizing repos",
+				Usage:   "remotes to exclude when organizing repos, using the same patterns as include-remotes",
 				Aliases: []string{"e"},
 			},
 			&cli.StringFlag{
@@ -57,8 +81,167 @@ func newApp() *cli.App {
 				Usage:   "strategy to use when organizing repos with multiple remotes",
 				Aliases: []string{"r"},
 			},
+			&cli.StringFlag{
+				Name:  "primary-remote",
+				Usage: "the remote repos are organized by",
+				Value: "origin",
+			},
+			&cli.StringSliceFlag{
+				Name:  "host-root",
+				Usage: "organize repos from a host into a different top level directory (absolute or relative to destination) as 'host=dir'",
+			},
+			&cli.StringSliceFlag{
+				Name:  "owner-alias",
+				Usage: "organize repos from an owner into a directory with a different name as 'owner=alias'",
+			},
+			&cli.BoolFlag{
+				Name:  "lowercase",
+				Usage: "convert remote owners and names to lowercase",
+			},
+			&cli.BoolFlag{
+				Name:  "normalize-unicode",
+				Usage: "convert remote owners and names to unicode normalization form C",
+			},
+			&cli.BoolFlag{
+				Name:  "replace-unsafe",
+				Usage: "replace characters in remote owners and names which are not letters, digits, '-', '_', or '.' with '-'",
+			},
+			&cli.BoolFlag{
+				Name:  "relative-links",
+				Usage: "create symlinks with paths relative to the link rather than absolute paths",
+			},
+			&cli.BoolFlag{
+				Name:  "verify-connectivity",
+				Usage: "check no objects are missing from organized repos before removing their staged copies",
+			},
+			&cli.BoolFlag{
+				Name:  "relink-worktrees",
+				Usage: "point linked worktrees outside of organized repos at the organized copy instead of the original",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ignore",
+				Usage: "glob patterns for the names of directories which should not be organized",
+			},
+			&cli.StringSliceFlag{
+				Name:  "hook",
+				Usage: "shell commands to run after each repo is organized",
+			},
+			&cli.BoolFlag{
+				Name:  "resume",
+				Usage: "organize the repos left by an interrupted run before any given dirs",
+			},
+			&cli.BoolFlag{
+				Name:  "wait",
+				Usage: "wait for any other run changing destination to finish instead of failing",
+			},
+			&cli.StringFlag{
+				Name:  "where",
+				Usage: "an expression selecting which repos are organized, listed, exported, synced, or run in, such as 'host == \"github.com\" && !dirty && remotes > 1'",
+			},
+			&cli.StringFlag{
+				Name:  "remote-protocol",
+				Usage: "convert the remote urls of organized repos to 'ssh' or 'https'",
+			},
+			&cli.StringSliceFlag{
+				Name:  "url-rewrite",
+				Usage: "replace a prefix of the remote urls of organized repos, like git's insteadOf, as 'prefix=replacement'",
+			},
+			&cli.BoolFlag{
+				Name:  "strip-credentials",
+				Usage: "remove credentials embedded in the remote urls of organized repos",
+			},
+			&cli.StringSliceFlag{
+				Name:  "rename-remote",
+				Usage: "rename a remote of organized repos as 'old=new'",
+			},
+			&cli.StringSliceFlag{
+				Name:  "fork-owner",
+				Usage: "rename the remote of organized repos owned by this owner to 'origin', and the remote it was forked from to 'upstream'",
+			},
+			&cli.StringFlag{
+				Name:  "backup-dir",
+				Usage: "the directory (absolute or relative to destination) where a bundle and untracked files of each repo are written before it is organized, if not specified no backups are made",
+			},
+			&cli.IntFlag{
+				Name:  "backup-keep",
+				Usage: "how many backups of each repo to keep, if not specified backups are not pruned by count",
+			},
+			&cli.DurationFlag{
+				Name:  "backup-max-age",
+				Usage: "how long to keep backups for, if not specified backups are not pruned by age",
+			},
 		},
 		Action: run,
+		Commands: []*cli.Command{
+			{
+				Name:      "watch",
+				Usage:     "organize new repos as they appear in a directory",
+				UsageText: "organize [arguments] watch [command arguments] dir",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{
+						Name:  "settle",
+						Usage: "how long a new repo must be left alone before it is organized",
+						Value: time.Second * 5,
+					},
+				},
+				Action: watch,
+			},
+			{
+				Name:      "export",
+				Usage:     "write a manifest of every organized repo",
+				UsageText: "organize [arguments] export [command arguments]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "output",
+						Usage:   "the file to write the manifest to, if not specified it is written to stdout",
+						Aliases: []string{"o"},
+					},
+				},
+				Action: export,
+			},
+			{
+				Name:      "restore",
+				Usage:     "clone every repo in a manifest into destination",
+				UsageText: "organize [arguments] restore [command arguments] manifest",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    "jobs",
+						Usage:   "how many repos to clone at a time",
+						Value:   4,
+						Aliases: []string{"j"},
+					},
+				},
+				Action: restore,
+			},
+			{
+				Name:      "sync",
+				Usage:     "fetch every remote of every organized repo and show how each repo compares to its upstream",
+				UsageText: "organize [arguments] sync [command arguments]",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    "jobs",
+						Usage:   "how many repos to fetch at a time",
+						Value:   8,
+						Aliases: []string{"j"},
+					},
+				},
+				Action: syncRepos,
+			},
+			{
+				Name:      "list",
+				Usage:     "print the path of every organized repo",
+				UsageText: "organize [arguments] list",
+				Action:    list,
+			},
+			{
+				Name:      "foreach",
+				Usage:     "run a shell command in every organized repo",
+				UsageText: "organize [arguments] foreach command...",
+				Action:    foreach,
+			},
+			quarantineCommand(),
+			backupCommand(),
+		},
 		Authors: []*cli.Author{
 			{
 				Name:  "Josh Meranda",
@@ -68,52 +251,431 @@ func newApp() *cli.App {
 	}
 }
 
-func organizeDir(config organize.Config, dir string) error {
-	logger.Printf("organizing dir '%s'", dir)
+// parseMapping parses values of the form 'key=value' into mapping.
+func parseMapping(mapping map[string]string, values []string) error {
+	for _, value := range values {
+		key, v, found := strings.Cut(value, "=")
+		if !found || key == "" || v == "" {
+			return fmt.Errorf("expected a value of the form 'key=value' but found '%s'", value)
+		}
 
-	items, err := os.ReadDir(dir)
-	if err != nil {
-		return err
+		mapping[key] = v
 	}
 
-	for _, item := range items {
-		if item.IsDir() {
-			repoPath := path.Join(dir, item.Name())
-			repo, err := git.PlainOpen(repoPath)
-			if err != nil {
-				logger.Printf("ERROR: could not open repo '%s': %s", repoPath, err)
-				continue
-			}
+	return nil
+}
 
-			if err := organize.OrganizeRepo(config, path.Join(dir, item.Name()), repo); err != nil {
-				logger.Printf("ERROR: could not organize repo '%s': %s", item.Name(), err)
-			} else {
-				logger.Printf("organized repo '%s'", path.Join(dir, item.Name()))
-			}
+// configFromArgs loads the config file, if any, and overrides its values with any flags which were set.
+func configFromArgs(args *cli.Context) (organize.Config, error) {
+	config := organize.NewDefaultConfig()
+
+	if args.IsSet("config") {
+		var err error
+		if config, err = organize.LoadConfig(args.String("config")); err != nil {
+			return config, err
 		}
 	}
 
-	return nil
+	stringFlags := map[string]*string{
+		"destination":      &config.Destination,
+		"bare-destination": &config.BareDestination,
+		"stage":            &config.Stage,
+		"quarantine":       &config.Quarantine,
+		"unsorted":         &config.Unsorted,
+		"broken":           &config.Broken,
+		"primary-remote":   &config.PrimaryRemote,
+		"backup-dir":       &config.BackupDir,
+		"where":            &config.Where,
+		"remote-protocol":  &config.RewriteRemotes.Protocol,
+	}
+	for name, value := range stringFlags {
+		if args.IsSet(name) {
+			*value = args.String(name)
+		}
+	}
+
+	sliceFlags := map[string]*[]string{
+		"include-remotes": &config.IncludeRemotes,
+		"exclude-remotes": &config.ExcludeRemotes,
+		"ignore":          &config.Ignore,
+		"hook":            &config.Hooks,
+		"fork-owner":      &config.RewriteRemotes.ForkOwners,
+	}
+	for name, value := range sliceFlags {
+		if args.IsSet(name) {
+			*value = args.StringSlice(name)
+		}
+	}
+
+	boolFlags := map[string]*bool{
+		"lowercase":           &config.Normalize.Lowercase,
+		"normalize-unicode":   &config.Normalize.Unicode,
+		"replace-unsafe":      &config.Normalize.ReplaceUnsafe,
+		"relative-links":      &config.RelativeLinks,
+		"verify-connectivity": &config.VerifyConnectivity,
+		"relink-worktrees":    &config.RelinkWorktrees,
+		"strip-credentials":   &config.RewriteRemotes.StripCredentials,
+	}
+	for name, value := range boolFlags {
+		if args.IsSet(name) {
+			*value = args.Bool(name)
+		}
+	}
+
+	if args.IsSet("backup-keep") {
+		config.BackupKeep = args.Int("backup-keep")
+	}
+
+	if args.IsSet("backup-max-age") {
+		config.BackupMaxAge = args.Duration("backup-max-age")
+	}
+
+	if args.IsSet("remote-strategy") {
+		config.RemoteStrategy = organize.MultipleRemoteStrategy(args.String("remote-strategy"))
+	}
+
+	if config.HostRoots == nil {
+		config.HostRoots = make(map[string]string)
+	}
+
+	if err := parseMapping(config.HostRoots, args.StringSlice("host-root")); err != nil {
+		return config, err
+	}
+
+	if config.OwnerAliases == nil {
+		config.OwnerAliases = make(map[string]string)
+	}
+
+	if err := parseMapping(config.OwnerAliases, args.StringSlice("owner-alias")); err != nil {
+		return config, err
+	}
+
+	mappingFlags := map[string]*map[string]string{
+		"url-rewrite":   &config.RewriteRemotes.URLRewrites,
+		"rename-remote": &config.RewriteRemotes.Renames,
+	}
+	for name, mapping := range mappingFlags {
+		if !args.IsSet(name) {
+			continue
+		}
+
+		if *mapping == nil {
+			*mapping = make(map[string]string)
+		}
+
+		if err := parseMapping(*mapping, args.StringSlice(name)); err != nil {
+			return config, err
+		}
+	}
+
+	return config, config.Validate()
+}
+
+// lockDestination locks the destination of o, and returns a function to release it.
+func lockDestination(ctx context.Context, o *organize.Organizer, wait bool) (func(), error) {
+	lock, err := o.Lock(ctx, wait)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		if err := lock.Release(); err != nil {
+			logger.Printf("ERROR: could not release lock: %s", err)
+		}
+	}, nil
 }
 
 func run(args *cli.Context) error {
-	config := organize.NewDefaultConfig()
-	config.Destination = args.String("destination")
-	config.Stage = args.String("stage")
-	config.Quarantine = args.String("quarantine")
-	config.IncludeRemotes = args.StringSlice("include-remotes")
-	config.ExcludeRemotes = args.StringSlice("exclude-remotes")
-	config.RemoteStrategy = organize.MultipleRemoteStrategy(args.String("remote-strategy"))
-
-	for _, dir := range args.Args().Slice() {
-		if err := organizeDir(config, dir); err != nil {
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(args.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// the current repo is finished or rolled back after the first signal, and a second one exits immediately
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	display, restoreLogger := showProgress()
+	defer restoreLogger()
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger), organize.WithProgress(display))
+
+	unlock, err := lockDestination(ctx, o, args.Bool("wait"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	report := &organize.Report{}
+
+	if args.Bool("resume") {
+		resumed, err := o.Resume(ctx)
+		if err != nil {
+			return err
+		}
+
+		report.Merge(resumed)
+	}
+
+	if args.NArg() != 0 {
+		organized, err := o.OrganizeAll(ctx, args.Args().Slice()...)
+		if err != nil {
 			logger.Printf("ERROR: %s", err)
 		}
+
+		report.Merge(organized)
+	}
+
+	return report.Write(os.Stdout)
+}
+
+func export(args *cli.Context) error {
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	manifest, err := o.Export(args.Context)
+	if err != nil {
+		return fmt.Errorf("could not export repos: %w", err)
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	if output := args.String("output"); output != "" {
+		return os.WriteFile(output, data, 0644)
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func restore(args *cli.Context) error {
+	if args.NArg() != 1 {
+		return fmt.Errorf("expected exactly one manifest to restore")
+	}
+
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := organize.ReadManifest(args.Args().First())
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(args.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	unlock, err := lockDestination(ctx, o, args.Bool("wait"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return o.Restore(ctx, manifest, args.Int("jobs")).Write(os.Stdout)
+}
+
+func syncRepos(args *cli.Context) error {
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(args.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	statuses, err := o.Sync(ctx, args.Int("jobs"))
+	if err != nil {
+		return fmt.Errorf("could not sync repos: %w", err)
+	}
+
+	return organize.WriteStatuses(os.Stdout, statuses)
+}
+
+func list(args *cli.Context) error {
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	repoPaths, err := o.List(args.Context)
+	if err != nil {
+		return fmt.Errorf("could not list repos: %w", err)
+	}
+
+	for _, repoPath := range repoPaths {
+		fmt.Println(repoPath)
 	}
 
 	return nil
 }
 
+func foreach(args *cli.Context) error {
+	if args.NArg() == 0 {
+		return fmt.Errorf("expected a command to run")
+	}
+
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(args.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	return o.ForEach(ctx, strings.Join(args.Args().Slice(), " "), os.Stdout)
+}
+
+func watch(args *cli.Context) error {
+	if args.NArg() != 1 {
+		return fmt.Errorf("expected exactly one directory to watch")
+	}
+
+	dir := args.Args().First()
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	settle := args.Duration("settle")
+
+	ctx, stop := signal.NotifyContext(args.Context, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("could not create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(dir); err != nil {
+		return fmt.Errorf("could not watch dir '%s': %w", dir, err)
+	}
+
+	fs := organize.NewOSFilesystem()
+
+	matcher, err := organize.NewIgnoreMatcher(fs, dir, config.Ignore)
+	if err != nil {
+		return err
+	}
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithFilesystem(fs), organize.WithLogger(logger))
+	report := &organize.Report{}
+
+	logger.Printf("watching dir '%s'", dir)
+
+	// pending maps new directories to the last time they were created or written to. The watch is not
+	// recursive, so changes inside a directory are not seen, and repos are checked for lock files instead.
+	pending := make(map[string]time.Time)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Printf("stopped watching dir '%s'", dir)
+			return report.Write(os.Stdout)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+
+			if path.Dir(event.Name) != path.Clean(dir) {
+				continue
+			}
+
+			if info, err := os.Stat(event.Name); err != nil || !info.IsDir() || config.IsManagedPath(event.Name) {
+				continue
+			}
+
+			if matcher.Match([]string{path.Base(event.Name)}, true) {
+				logger.Printf("skipping ignored dir '%s'", event.Name)
+				report.Add(event.Name, organize.StatusSkipped, "matched ignore rules")
+				continue
+			}
+
+			pending[event.Name] = time.Now()
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+
+			logger.Printf("ERROR: %s", err)
+		case now := <-ticker.C:
+			for repoPath, seen := range pending {
+				if now.Sub(seen) < settle {
+					continue
+				}
+
+				if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+					delete(pending, repoPath)
+					continue
+				}
+
+				// writes inside of a directory are not seen, so there is no telling when a directory which is not
+				// a repo, such as an archive still being extracted, is complete, and it is left pending until it
+				// becomes a repo or is removed
+				isRepo, err := organize.IsRepo(fs, repoPath)
+				if err != nil {
+					logger.Printf("ERROR: %s", err)
+					continue
+				}
+
+				if !isRepo {
+					continue
+				}
+
+				settled, err := organize.IsRepoSettled(fs, repoPath)
+				if err != nil {
+					logger.Printf("ERROR: %s", err)
+					continue
+				}
+
+				if !settled {
+					continue
+				}
+
+				// the lock is only held while organizing, so other runs are not blocked for as long as this
+				// one is watching
+				unlock, err := lockDestination(ctx, o, true)
+				if err != nil {
+					logger.Printf("ERROR: %s", err)
+					continue
+				}
+
+				delete(pending, repoPath)
+				report.Merge(o.OrganizePaths(ctx, []string{repoPath}))
+				unlock()
+			}
+		}
+	}
+}
+
 func main() {
 	app := newApp()
 	if err := app.Run(os.Args); err != nil {
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	organize "organize/pkg"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	// terminalInterval is how often the progress line is redrawn when stderr is a terminal
+	terminalInterval = time.Second / 5
+
+	// logInterval is how often progress is logged when stderr is not a terminal
+	logInterval = time.Second * 10
+)
+
+// progressDisplay shows the progress of organizing repos, either as a line on a terminal which is redrawn in
+// place, or as periodic log lines.
+type progressDisplay struct {
+	*organize.ProgressTracker
+
+	out      *os.File
+	terminal bool
+
+	// mu guards writes to out, so log lines do not get mixed up with the progress line.
+	mu      sync.Mutex
+	shown   bool
+	running bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// newProgressDisplay creates a progressDisplay which writes to out, and redraws in place if out is a terminal.
+func newProgressDisplay(out *os.File) *progressDisplay {
+	return &progressDisplay{
+		ProgressTracker: organize.NewProgressTracker(),
+		out:             out,
+		terminal:        term.IsTerminal(int(out.Fd())),
+	}
+}
+
+func (d *progressDisplay) Start(repos int, bytes int64) {
+	d.ProgressTracker.Start(repos, bytes)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.running || repos == 0 {
+		return
+	}
+
+	d.running = true
+	d.stop = make(chan struct{})
+	d.done = make(chan struct{})
+
+	interval := logInterval
+	if d.terminal {
+		interval = terminalInterval
+	}
+
+	go d.loop(interval, d.stop, d.done)
+}
+
+func (d *progressDisplay) Finish() {
+	d.mu.Lock()
+	if !d.running {
+		d.mu.Unlock()
+		return
+	}
+
+	d.running = false
+	close(d.stop)
+	done := d.done
+	d.mu.Unlock()
+
+	<-done
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clear()
+}
+
+func (d *progressDisplay) loop(interval time.Duration, stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.mu.Lock()
+			if d.terminal {
+				d.draw()
+			} else {
+				logger.Print(progressLog(d.State(), time.Now()))
+			}
+			d.mu.Unlock()
+		}
+	}
+}
+
+// Write writes p to out, first clearing the progress line and then redrawing it underneath. Logs are written
+// through the display while it is shown on a terminal.
+func (d *progressDisplay) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.clear()
+	n, err := d.out.Write(p)
+
+	if d.running {
+		d.draw()
+	}
+
+	return n, err
+}
+
+// clear removes the progress line if it is shown. mu must be held.
+func (d *progressDisplay) clear() {
+	if d.shown {
+		fmt.Fprint(d.out, "\r\033[K")
+		d.shown = false
+	}
+}
+
+// draw replaces the progress line with the current progress. mu must be held.
+func (d *progressDisplay) draw() {
+	line := progressLine(d.State(), time.Now())
+
+	// a line which wraps cannot be redrawn in place
+	if width, _, err := term.GetSize(int(d.out.Fd())); err == nil && width > 0 {
+		if runes := []rune(line); len(runes) >= width {
+			line = string(runes[:width-1])
+		}
+	}
+
+	fmt.Fprintf(d.out, "\r\033[K%s", line)
+	d.shown = true
+}
+
+// progressLine describes state on a single line for a terminal.
+func progressLine(state organize.ProgressState, now time.Time) string {
+	line := fmt.Sprintf("[%d/%d] %s/%s %s", state.ReposDone, state.Repos, formatBytes(state.BytesCopied),
+		formatBytes(state.Bytes), formatPercent(state))
+
+	if eta, ok := state.ETA(now); ok {
+		line += fmt.Sprintf(" ETA %s", formatDuration(eta))
+	}
+
+	if state.Current != "" {
+		line += " " + state.Current
+	}
+
+	return line
+}
+
+// progressLog describes state as a log line.
+func progressLog(state organize.ProgressState, now time.Time) string {
+	line := fmt.Sprintf("progress: %d/%d repos, %s of %s copied (%s)", state.ReposDone, state.Repos,
+		formatBytes(state.BytesCopied), formatBytes(state.Bytes), formatPercent(state))
+
+	if eta, ok := state.ETA(now); ok {
+		line += fmt.Sprintf(", ETA %s", formatDuration(eta))
+	}
+
+	if state.Current != "" {
+		line += fmt.Sprintf(", organizing '%s'", state.Current)
+	}
+
+	return line
+}
+
+// showProgress creates a progressDisplay on stderr, and returns a function to stop logging through it.
+func showProgress() (*progressDisplay, func()) {
+	display := newProgressDisplay(os.Stderr)
+	if !display.terminal {
+		return display, func() {}
+	}
+
+	previous := logger.Writer()
+	logger.SetOutput(display)
+
+	return display, func() {
+		logger.SetOutput(previous)
+	}
+}
+
+func formatPercent(state organize.ProgressState) string {
+	if state.Bytes <= 0 {
+		return "100%"
+	}
+
+	percent := state.BytesCopied * 100 / state.Bytes
+	if percent > 100 {
+		percent = 100
+	}
+
+	return fmt.Sprintf("%d%%", percent)
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Second {
+		return "0s"
+	}
+
+	return d.Round(time.Second).String()
+}
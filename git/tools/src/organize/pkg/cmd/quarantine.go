@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	organize "organize/pkg"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+func quarantineCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "quarantine",
+		Usage:     "manage repos which were quarantined",
+		UsageText: "organize [arguments] quarantine command [command arguments]",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "list",
+				Usage:     "list quarantined repos with their remotes and why they were quarantined",
+				UsageText: "organize [arguments] quarantine list",
+				Action:    quarantineList,
+			},
+			{
+				Name:      "resolve",
+				Usage:     "organize a quarantined repo by choosing a remote or strategy",
+				UsageText: "organize [arguments] quarantine resolve [command arguments] [repo]",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "remote",
+						Usage: "the remote to organize the repo by, any other remotes are ignored unless a strategy is also given",
+					},
+					&cli.StringFlag{
+						Name:  "strategy",
+						Usage: "the strategy to use for the repo's remotes",
+					},
+					&cli.BoolFlag{
+						Name:    "interactive",
+						Usage:   "prompt for how to resolve each quarantined repo",
+						Aliases: []string{"i"},
+					},
+				},
+				Action: quarantineResolve,
+			},
+		},
+	}
+}
+
+func quarantineList(args *cli.Context) error {
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+
+	repos, err := o.ListQuarantined(args.Context)
+	if err != nil {
+		return fmt.Errorf("could not list quarantined repos: %w", err)
+	}
+
+	return organize.WriteQuarantined(os.Stdout, repos)
+}
+
+// resolveOne resolves a single quarantined repo and records the result in report.
+func resolveOne(ctx context.Context, o *organize.Organizer, report *organize.Report, repoPath string, primary string, strategy organize.MultipleRemoteStrategy) {
+	plan, err := o.ResolveQuarantined(ctx, repoPath, primary, strategy)
+	if err != nil {
+		logger.Printf("ERROR: could not resolve repo '%s': %s", repoPath, err)
+		report.Add(repoPath, organize.StatusFailed, err.Error())
+		return
+	}
+
+	logger.Printf("organized repo '%s' into '%s'", repoPath, plan.Source)
+	report.Add(repoPath, organize.StatusOrganized, fmt.Sprintf("organized into '%s'", plan.Source))
+}
+
+// prompt asks how to resolve repo, and returns the chosen remote and strategy. If the repo should be
+// skipped both are empty, and if no more repos should be resolved quit is true.
+func prompt(in *bufio.Reader, out io.Writer, repo organize.QuarantinedRepo) (string, organize.MultipleRemoteStrategy, bool, error) {
+	fmt.Fprintf(out, "\n%s\n", repo.Path)
+
+	if repo.Metadata.Reason != "" {
+		fmt.Fprintf(out, "  reason: %s\n", repo.Metadata.Reason)
+	}
+
+	for i, remote := range repo.Remotes {
+		fmt.Fprintf(out, "  %d) %s %s\n", i+1, remote.Name, strings.Join(remote.URLs, ", "))
+	}
+
+	for {
+		fmt.Fprint(out, "organize by remote [number], (s)ymlink, s(k)ip, or (q)uit: ")
+
+		line, err := in.ReadString('\n')
+		if err == io.EOF {
+			return "", "", true, nil
+		} else if err != nil {
+			return "", "", false, err
+		}
+
+		switch answer := strings.TrimSpace(line); answer {
+		case "s":
+			return "", organize.StrategySymlink, false, nil
+		case "k", "":
+			return "", "", false, nil
+		case "q":
+			return "", "", true, nil
+		default:
+			if n, err := strconv.Atoi(answer); err == nil && n > 0 && n <= len(repo.Remotes) {
+				return repo.Remotes[n-1].Name, "", false, nil
+			}
+
+			fmt.Fprintf(out, "unrecognized answer '%s'\n", answer)
+		}
+	}
+}
+
+func quarantineResolve(args *cli.Context) error {
+	config, err := configFromArgs(args)
+	if err != nil {
+		return err
+	}
+
+	o := organize.NewOrganizer(organize.WithConfig(config), organize.WithLogger(logger))
+	report := &organize.Report{}
+
+	unlock, err := lockDestination(args.Context, o, args.Bool("wait"))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if args.Bool("interactive") {
+		repos, err := o.ListQuarantined(args.Context)
+		if err != nil {
+			return fmt.Errorf("could not list quarantined repos: %w", err)
+		}
+
+		in := bufio.NewReader(args.App.Reader)
+
+		for _, repo := range repos {
+			if repo.Err != nil {
+				report.Add(repo.Path, organize.StatusSkipped, repo.Err.Error())
+				continue
+			}
+
+			primary, strategy, quit, err := prompt(in, args.App.Writer, repo)
+			if err != nil {
+				return err
+			}
+
+			if quit {
+				break
+			}
+
+			if primary == "" && strategy == organize.StrategyDefault {
+				report.Add(repo.Path, organize.StatusSkipped, "skipped by user")
+				continue
+			}
+
+			resolveOne(args.Context, o, report, repo.Path, primary, strategy)
+		}
+
+		return report.Write(os.Stdout)
+	}
+
+	if args.NArg() != 1 {
+		return fmt.Errorf("expected exactly one repo to resolve")
+	}
+
+	primary := args.String("remote")
+	strategy := organize.MultipleRemoteStrategy(args.String("strategy"))
+
+	if primary == "" && strategy == organize.StrategyDefault {
+		return fmt.Errorf("one of --remote or --strategy is required")
+	}
+
+	// repos can be given by their name in the quarantine
+	repoPath := args.Args().First()
+	if !strings.Contains(repoPath, "/") {
+		repoPath = path.Join(config.QuarantinePath(), repoPath)
+	}
+
+	resolveOne(args.Context, o, report, repoPath, primary, strategy)
+
+	return report.Write(os.Stdout)
+}
@@ -0,0 +1,272 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// changeFilesystem is implemented by filesystems which can change file metadata, which is needed to
+// preserve permissions and modification times when copying.
+type changeFilesystem interface {
+	Chmod(name string, mode os.FileMode) error
+
+	// Lchtimes changes the times of name, without following symlinks.
+	Lchtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// xattrFilesystem is implemented by filesystems which support extended attributes, or which report
+// errNotSupported on platforms without them. Symlinks are not followed.
+type xattrFilesystem interface {
+	Lxattrs(name string) (map[string][]byte, error)
+	SetLxattr(name string, attr string, value []byte) error
+}
+
+// errNotSupported is recorded when the filesystem cannot preserve some metadata at all.
+var errNotSupported = errors.New("not supported by the filesystem")
+
+// copyLosses records the file metadata which could not be preserved while copying.
+type copyLosses struct {
+	kinds  []string
+	counts map[string]int
+	errs   map[string]error
+}
+
+func (losses *copyLosses) add(kind string, err error) {
+	if losses.counts == nil {
+		losses.counts = make(map[string]int)
+		losses.errs = make(map[string]error)
+	}
+
+	if losses.counts[kind] == 0 {
+		losses.kinds = append(losses.kinds, kind)
+		losses.errs[kind] = err
+	}
+
+	losses.counts[kind]++
+}
+
+// warnings describes each kind of loss, along with how many files it affected and the first error.
+func (losses *copyLosses) warnings() []string {
+	warnings := make([]string, 0, len(losses.kinds))
+	for _, kind := range losses.kinds {
+		warnings = append(warnings, fmt.Sprintf("%s not preserved for %d files: %s", kind, losses.counts[kind], losses.errs[kind]))
+	}
+
+	return warnings
+}
+
+// permMode returns the parts of mode which Chmod can set.
+func permMode(mode os.FileMode) os.FileMode {
+	return mode & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+}
+
+// contextReader is a reader which stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+// copyFile copies the contents of the regular file src to dst, stopping early if ctx is done. If copied is not
+// nil it is called with the number of bytes copied as the copy progresses.
+func copyFile(ctx context.Context, fs billy.Filesystem, src string, dst string, mode os.FileMode, copied func(int64)) (err error) {
+	in, err := fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := fs.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var r io.Reader = contextReader{ctx: ctx, r: in}
+	if copied != nil {
+		r = countingReader{r: r, copied: copied}
+	}
+
+	_, err = io.Copy(out, r)
+	return err
+}
+
+// copier copies directory trees within a filesystem, preserving as much file metadata as the filesystem
+// allows.
+type copier struct {
+	ctx    context.Context
+	fs     billy.Filesystem
+	losses copyLosses
+
+	// copied is called with the number of bytes copied as file contents are copied, if not nil
+	copied func(int64)
+}
+
+// preserve copies the metadata of the file described by info at src to dst.
+func (c *copier) preserve(src string, dst string, info os.FileInfo) {
+	isLink := info.Mode()&os.ModeSymlink != 0
+
+	if fs, ok := c.fs.(xattrFilesystem); ok {
+		// a filesystem which cannot hold extended attributes has none to lose
+		attrs, err := fs.Lxattrs(src)
+		if err != nil && !errors.Is(err, errNotSupported) {
+			c.losses.add("extended attributes", err)
+		}
+
+		for attr, value := range attrs {
+			if err := fs.SetLxattr(dst, attr, value); err != nil {
+				c.losses.add("extended attributes", fmt.Errorf("could not set '%s' on '%s': %w", attr, dst, err))
+			}
+		}
+	} else {
+		c.losses.add("extended attributes", errNotSupported)
+	}
+
+	fs, ok := c.fs.(changeFilesystem)
+	if !ok {
+		c.losses.add("modification times", errNotSupported)
+		return
+	}
+
+	// symlinks do not have permissions of their own
+	if !isLink {
+		if err := fs.Chmod(dst, permMode(info.Mode())); err != nil {
+			c.losses.add("permissions", fmt.Errorf("could not change mode of '%s': %w", dst, err))
+		}
+	}
+
+	if err := fs.Lchtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		c.losses.add("modification times", fmt.Errorf("could not change times of '%s': %w", dst, err))
+	}
+}
+
+// clear removes whatever is at target so that the file described by info can be copied over it. Directories
+// are only removed if info is not a directory, so they can be copied into.
+func (c *copier) clear(target string, info os.FileInfo) error {
+	existing, err := c.fs.Lstat(target)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if existing.IsDir() && info.IsDir() {
+		return nil
+	}
+
+	return util.RemoveAll(c.fs, target)
+}
+
+// copyDir recursively copies src to dst, replacing anything already at dst. Symlinks are always copied as symlinks rather than being followed,
+// and files which are neither regular files, directories, nor symlinks are skipped.
+func (c *copier) copyDir(src string, dst string) error {
+	type dir struct {
+		path string
+		info os.FileInfo
+	}
+
+	// directories are only given their final permissions and times once their contents have been copied
+	var dirs []dir
+
+	err := util.Walk(c.fs, src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := c.ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(dst, rel)
+
+		if err := c.clear(target, info); err != nil {
+			return err
+		}
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := c.fs.Readlink(p)
+			if err != nil {
+				return err
+			}
+
+			if err := c.fs.Symlink(link, target); err != nil {
+				return err
+			}
+		case info.IsDir():
+			// the directory must stay writable until everything inside of it is copied
+			if err := c.fs.MkdirAll(target, info.Mode().Perm()|0700); err != nil {
+				return err
+			}
+
+			dirs = append(dirs, dir{path: p, info: info})
+			return nil
+		case info.Mode().IsRegular():
+			if err := copyFile(c.ctx, c.fs, p, target, info.Mode(), c.copied); err != nil {
+				return err
+			}
+		default:
+			c.losses.add("special files", fmt.Errorf("'%s' was skipped: unsupported file type %s", p, info.Mode().Type()))
+			return nil
+		}
+
+		c.preserve(p, target, info)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(src, dirs[i].path)
+		if err != nil {
+			return err
+		}
+
+		c.preserve(dirs[i].path, path.Join(dst, rel), dirs[i].info)
+	}
+
+	return nil
+}
+
+// copy recursively copies src to dst, and returns a description of any file metadata which could not be
+// preserved.
+func (c *copier) copy(src string, dst string) ([]string, error) {
+	if err := c.copyDir(src, dst); err != nil {
+		return nil, err
+	}
+
+	return c.losses.warnings(), nil
+}
+
+// copyDir recursively copies src to dst, and returns a description of any file metadata which could not be
+// preserved. If ctx is done the copy stops, leaving dst partially written.
+func copyDir(ctx context.Context, fs billy.Filesystem, src string, dst string) ([]string, error) {
+	return (&copier{ctx: ctx, fs: fs}).copy(src, dst)
+}
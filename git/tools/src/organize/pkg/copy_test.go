@@ -0,0 +1,135 @@
+package organize
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyDir(t *testing.T) {
+	t.Run("Metadata", func(t *testing.T) {
+		fs := NewOSFilesystem()
+		src := path.Join(t.TempDir(), "src")
+		dst := path.Join(t.TempDir(), "dst")
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		require.NoError(t, util.WriteFile(fs, path.Join(src, "bin", "script.sh"), []byte("#!/bin/sh\n"), 0755))
+		require.NoError(t, util.WriteFile(fs, path.Join(src, "private.txt"), []byte("secret"), 0600))
+		require.NoError(t, os.Chmod(path.Join(src, "bin", "script.sh"), 0751))
+		require.NoError(t, os.Chtimes(path.Join(src, "private.txt"), mtime, mtime))
+		require.NoError(t, os.Chtimes(path.Join(src, "bin"), mtime, mtime))
+
+		warnings, err := copyDir(context.Background(), fs, src, dst)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+
+		info, err := os.Stat(path.Join(dst, "bin", "script.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0751), info.Mode().Perm())
+
+		info, err = os.Stat(path.Join(dst, "private.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		assert.True(t, mtime.Equal(info.ModTime()))
+
+		info, err = os.Stat(path.Join(dst, "bin"))
+		require.NoError(t, err)
+		assert.True(t, mtime.Equal(info.ModTime()))
+	})
+
+	t.Run("Symlinks", func(t *testing.T) {
+		fs := NewOSFilesystem()
+		src := path.Join(t.TempDir(), "src")
+		dst := path.Join(t.TempDir(), "dst")
+
+		require.NoError(t, util.WriteFile(fs, path.Join(src, "file.txt"), []byte("data"), 0644))
+		require.NoError(t, os.Symlink("file.txt", path.Join(src, "relative")))
+		require.NoError(t, os.Symlink(path.Join(src, "file.txt"), path.Join(src, "absolute")))
+		require.NoError(t, os.Symlink("missing", path.Join(src, "dangling")))
+
+		warnings, err := copyDir(context.Background(), fs, src, dst)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+
+		for name, target := range map[string]string{
+			"relative": "file.txt",
+			"absolute": path.Join(src, "file.txt"),
+			"dangling": "missing",
+		} {
+			link, err := os.Readlink(path.Join(dst, name))
+			require.NoError(t, err, name)
+			assert.Equal(t, target, link, name)
+		}
+	})
+
+	t.Run("Xattrs", func(t *testing.T) {
+		fs := NewOSFilesystem()
+		src := path.Join(t.TempDir(), "src")
+		dst := path.Join(t.TempDir(), "dst")
+
+		xfs := fs.(xattrFilesystem)
+
+		require.NoError(t, util.WriteFile(fs, path.Join(src, "file.txt"), []byte("data"), 0644))
+		if err := xfs.SetLxattr(path.Join(src, "file.txt"), "user.organize", []byte("value")); err != nil {
+			t.Skipf("extended attributes are not supported: %s", err)
+		}
+
+		warnings, err := copyDir(context.Background(), fs, src, dst)
+		require.NoError(t, err)
+		assert.Empty(t, warnings)
+
+		attrs, err := xfs.Lxattrs(path.Join(dst, "file.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value"), attrs["user.organize"])
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		fs := memfs.New()
+
+		require.NoError(t, util.WriteFile(fs, "/src/a.txt", []byte("a"), 0644))
+		require.NoError(t, util.WriteFile(fs, "/src/b.txt", []byte("b"), 0644))
+
+		warnings, err := copyDir(context.Background(), fs, "/src", "/dst")
+		require.NoError(t, err)
+		require.Len(t, warnings, 2)
+		assert.Contains(t, warnings[0], "extended attributes not preserved for 3 files")
+		assert.Contains(t, warnings[1], "modification times not preserved for 3 files")
+
+		data, err := util.ReadFile(fs, "/dst/b.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "b", string(data))
+	})
+
+	t.Run("XattrsUnsupported", func(t *testing.T) {
+		fs := noXattrFilesystem{memfs.New()}
+
+		require.NoError(t, util.WriteFile(fs, "/src/a.txt", []byte("a"), 0644))
+
+		warnings, err := copyDir(context.Background(), fs, "/src", "/dst")
+		require.NoError(t, err)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "modification times not preserved")
+	})
+}
+
+// noXattrFilesystem reports extended attributes are not supported, as osFilesystem does on platforms without
+// them.
+type noXattrFilesystem struct {
+	billy.Filesystem
+}
+
+func (fs noXattrFilesystem) Lxattrs(name string) (map[string][]byte, error) {
+	return nil, errNotSupported
+}
+
+func (fs noXattrFilesystem) SetLxattr(name string, attr string, value []byte) error {
+	return errNotSupported
+}
@@ -0,0 +1,165 @@
+package organize
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/helper/chroot"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/go-git/go-git/v5/storage/filesystem/dotgit"
+)
+
+// osFilesystem is the OS filesystem, which unlike osfs.New accepts both absolute paths and paths relative
+// to the working directory.
+type osFilesystem struct {
+	billy.Filesystem
+}
+
+// NewOSFilesystem returns a filesystem backed by the OS.
+func NewOSFilesystem() billy.Filesystem {
+	return osFilesystem{Filesystem: chroot.New(osfs.Default, "/")}
+}
+
+func (fs osFilesystem) abs(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+
+	return p
+}
+
+func (fs osFilesystem) Create(filename string) (billy.File, error) {
+	return fs.Filesystem.Create(fs.abs(filename))
+}
+
+func (fs osFilesystem) Open(filename string) (billy.File, error) {
+	return fs.Filesystem.Open(fs.abs(filename))
+}
+
+func (fs osFilesystem) OpenFile(filename string, flag int, perm os.FileMode) (billy.File, error) {
+	return fs.Filesystem.OpenFile(fs.abs(filename), flag, perm)
+}
+
+func (fs osFilesystem) Stat(filename string) (os.FileInfo, error) {
+	return fs.Filesystem.Stat(fs.abs(filename))
+}
+
+func (fs osFilesystem) Rename(oldpath, newpath string) error {
+	return fs.Filesystem.Rename(fs.abs(oldpath), fs.abs(newpath))
+}
+
+func (fs osFilesystem) Remove(filename string) error {
+	return fs.Filesystem.Remove(fs.abs(filename))
+}
+
+func (fs osFilesystem) TempFile(dir, prefix string) (billy.File, error) {
+	return fs.Filesystem.TempFile(fs.abs(dir), prefix)
+}
+
+func (fs osFilesystem) ReadDir(p string) ([]os.FileInfo, error) {
+	return fs.Filesystem.ReadDir(fs.abs(p))
+}
+
+func (fs osFilesystem) MkdirAll(filename string, perm os.FileMode) error {
+	return fs.Filesystem.MkdirAll(fs.abs(filename), perm)
+}
+
+func (fs osFilesystem) Lstat(filename string) (os.FileInfo, error) {
+	return fs.Filesystem.Lstat(fs.abs(filename))
+}
+
+func (fs osFilesystem) Symlink(target, link string) error {
+	return fs.Filesystem.Symlink(target, fs.abs(link))
+}
+
+func (fs osFilesystem) Readlink(link string) (string, error) {
+	return fs.Filesystem.Readlink(fs.abs(link))
+}
+
+func (fs osFilesystem) Chroot(p string) (billy.Filesystem, error) {
+	return fs.Filesystem.Chroot(fs.abs(p))
+}
+
+func (fs osFilesystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(fs.abs(name), mode)
+}
+
+// absPath returns p as an absolute path in fs. Relative paths are relative to the working directory in the OS
+// filesystem, and to the root of any other filesystem.
+func absPath(fs billy.Filesystem, p string) string {
+	if fs, ok := fs.(osFilesystem); ok {
+		return fs.abs(p)
+	}
+
+	return path.Join("/", p)
+}
+
+// dotGitCommonDir returns the common git directory for gitDir if it is a linked worktree's git directory,
+// or nil otherwise.
+func dotGitCommonDir(fs billy.Filesystem, gitDir string) (billy.Filesystem, error) {
+	data, err := util.ReadFile(fs, path.Join(gitDir, "commondir"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	commonDir := strings.TrimSpace(string(data))
+	if !path.IsAbs(commonDir) {
+		commonDir = path.Join(gitDir, commonDir)
+	}
+
+	if _, err := fs.Stat(commonDir); os.IsNotExist(err) {
+		return nil, git.ErrRepositoryIncomplete
+	} else if err != nil {
+		return nil, err
+	}
+
+	return fs.Chroot(commonDir)
+}
+
+// openRepo opens the repository at repoPath in fs, the same way git.PlainOpenWithOptions would with
+// EnableDotGitCommonDir set.
+func openRepo(fs billy.Filesystem, repoPath string) (*git.Repository, error) {
+	gitDir, err := resolveGitDir(fs, repoPath)
+	if os.IsNotExist(err) {
+		// bare repositories are their own git directory
+		gitDir = repoPath
+	} else if err != nil {
+		return nil, err
+	}
+
+	if _, err := fs.Stat(gitDir); os.IsNotExist(err) {
+		return nil, git.ErrRepositoryNotExists
+	} else if err != nil {
+		return nil, err
+	}
+
+	dot, err := fs.Chroot(gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	common, err := dotGitCommonDir(fs, gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var worktree billy.Filesystem
+	if gitDir != repoPath {
+		if worktree, err = fs.Chroot(repoPath); err != nil {
+			return nil, err
+		}
+	}
+
+	storage := filesystem.NewStorage(dotgit.NewRepositoryFilesystem(dot, common), cache.NewObjectLRUDefault())
+
+	return git.Open(storage, worktree)
+}
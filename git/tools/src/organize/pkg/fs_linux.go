@@ -0,0 +1,58 @@
+package organize
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Lchtimes changes the access and modification times of name without following symlinks.
+func (fs osFilesystem) Lchtimes(name string, atime time.Time, mtime time.Time) error {
+	ts := []unix.Timespec{unix.NsecToTimespec(atime.UnixNano()), unix.NsecToTimespec(mtime.UnixNano())}
+	return unix.UtimesNanoAt(unix.AT_FDCWD, fs.abs(name), ts, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// Lxattrs returns the extended attributes of name without following symlinks.
+func (fs osFilesystem) Lxattrs(name string) (map[string][]byte, error) {
+	name = fs.abs(name)
+
+	size, err := unix.Llistxattr(name, nil)
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	} else if err != nil || size == 0 {
+		return nil, err
+	}
+
+	buf := make([]byte, size)
+	if size, err = unix.Llistxattr(name, buf); err != nil {
+		return nil, err
+	}
+
+	attrs := make(map[string][]byte)
+	for _, attr := range bytes.Split(buf[:size], []byte{0}) {
+		if len(attr) == 0 {
+			continue
+		}
+
+		size, err := unix.Lgetxattr(name, string(attr), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		value := make([]byte, size)
+		if size, err = unix.Lgetxattr(name, string(attr), value); err != nil {
+			return nil, err
+		}
+
+		attrs[string(attr)] = value[:size]
+	}
+
+	return attrs, nil
+}
+
+// SetLxattr sets an extended attribute of name without following symlinks.
+func (fs osFilesystem) SetLxattr(name string, attr string, value []byte) error {
+	return unix.Lsetxattr(fs.abs(name), attr, value, 0)
+}
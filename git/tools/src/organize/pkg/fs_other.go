@@ -0,0 +1,33 @@
+//go:build !linux
+
+package organize
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// Lchtimes changes the access and modification times of name. Symlinks are not supported.
+func (fs osFilesystem) Lchtimes(name string, atime time.Time, mtime time.Time) error {
+	info, err := os.Lstat(fs.abs(name))
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		return errors.New("changing the times of symlinks is not supported on this platform")
+	}
+
+	return os.Chtimes(fs.abs(name), atime, mtime)
+}
+
+// Lxattrs reports that extended attributes are not supported on this platform, so there are none to preserve.
+func (fs osFilesystem) Lxattrs(name string) (map[string][]byte, error) {
+	return nil, errNotSupported
+}
+
+// SetLxattr reports that extended attributes are not supported on this platform.
+func (fs osFilesystem) SetLxattr(name string, attr string, value []byte) error {
+	return errNotSupported
+}
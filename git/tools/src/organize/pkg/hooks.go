@@ -0,0 +1,53 @@
+package organize
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// hookEnv returns the environment hook commands are run with for plan. Paths are absolute, since hooks may
+// change their working directory.
+func hookEnv(fs billy.Filesystem, plan RepoPlan) []string {
+	return append(os.Environ(),
+		"ORGANIZE_OLD_PATH="+absPath(fs, plan.RepoPath),
+		"ORGANIZE_NEW_PATH="+absPath(fs, plan.Source),
+		"ORGANIZE_OWNER="+plan.Owner,
+		"ORGANIZE_NAME="+plan.Name,
+		"ORGANIZE_STRATEGY="+string(plan.Strategy),
+	)
+}
+
+// runCommandHooks runs each of the plan's hooks once the repo has been organized, and returns an error for
+// every hook which failed. A failing hook does not stop later hooks from being run.
+func (o *Organizer) runCommandHooks(ctx context.Context, plan RepoPlan) []error {
+	var errs []error
+
+	for _, hook := range plan.Hooks {
+		var output bytes.Buffer
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+		cmd.Env = hookEnv(o.fs, plan)
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+
+		o.logger.Printf("running hook '%s' for repo '%s'", hook, plan.RepoPath)
+
+		if err := cmd.Run(); err != nil {
+			if out := strings.TrimSpace(output.String()); out != "" {
+				err = fmt.Errorf("%w: %s", err, out)
+			}
+
+			errs = append(errs, fmt.Errorf("hook '%s' failed for repo '%s': %w", hook, plan.RepoPath, err))
+		} else if output.Len() > 0 {
+			o.logger.Printf("hook '%s' output: %s", hook, strings.TrimSpace(output.String()))
+		}
+	}
+
+	return errs
+}
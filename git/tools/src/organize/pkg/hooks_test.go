@@ -0,0 +1,105 @@
+package organize
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandHooks(t *testing.T) {
+	t.Run("Environment", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, _ := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		out := path.Join(tempDir, "hook.out")
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.Hooks = []string{`echo "$ORGANIZE_OLD_PATH $ORGANIZE_NEW_PATH $ORGANIZE_OWNER $ORGANIZE_NAME $ORGANIZE_STRATEGY" > ` + out}
+
+		report := NewOrganizer(WithConfig(cfg)).OrganizePaths(context.Background(), []string{repoDir})
+		assert.Equal(t, []ReportEntry{{Path: repoDir, Status: StatusOrganized}}, report.Entries)
+
+		newRepoDir := path.Join(cfg.Destination, "originuser", "origin")
+		assertFileContents(t, repoDir+" "+newRepoDir+" originuser origin origin", out)
+	})
+
+	t.Run("NormalizedAndQuarantined", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, _ := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{
+			{Name: "origin", URLs: []string{"git@github.com:OriginUser/Origin.git"}},
+			remoteUpstream,
+		})
+
+		out := path.Join(tempDir, "hook.out")
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.Normalize.Lowercase = true
+		cfg.OwnerAliases = map[string]string{"originuser": "me"}
+		cfg.Hooks = []string{`echo "$ORGANIZE_NEW_PATH $ORGANIZE_OWNER $ORGANIZE_NAME $ORGANIZE_STRATEGY" > ` + out}
+
+		report := NewOrganizer(WithConfig(cfg)).OrganizePaths(context.Background(), []string{repoDir})
+		assert.Equal(t, 1, report.Count(StatusOrganized))
+
+		newRepoDir := path.Join(cfg.QuarantinePath(), RepoBaseName)
+		assertFileContents(t, newRepoDir+" me origin quarantine", out)
+	})
+
+	t.Run("RuleHooks", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, _ := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		out := path.Join(tempDir, "hook.out")
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.Hooks = []string{"echo global >> " + out}
+		cfg.Rules = []Rule{
+			{Match: RuleMatch{Owner: "other"}, Hooks: []string{"echo other >> " + out}},
+			{Match: RuleMatch{Owner: "originuser"}, Hooks: []string{"echo rule >> " + out}},
+		}
+
+		report := NewOrganizer(WithConfig(cfg)).OrganizePaths(context.Background(), []string{repoDir})
+		assert.Equal(t, 1, report.Count(StatusOrganized))
+		assertFileContents(t, "global\nrule", out)
+	})
+
+	t.Run("ExecuteAndOrganizeRepo", func(t *testing.T) {
+		tempDir := t.TempDir()
+		first, firstRepo := RepoWithRemotes(t, path.Join(tempDir, "first"), []*config.RemoteConfig{remoteOrigin})
+		second, secondRepo := RepoWithRemotes(t, path.Join(tempDir, "second"), []*config.RemoteConfig{{Name: "origin", URLs: remoteUpstream.URLs}})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.Hooks = []string{`touch "$ORGANIZE_NEW_PATH/hooked"`}
+
+		o := NewOrganizer(WithConfig(cfg))
+		plan, err := o.Plan(context.Background(), first, firstRepo)
+		require.NoError(t, err)
+		require.NoError(t, o.Execute(context.Background(), plan))
+		assert.FileExists(t, path.Join(plan.Source, "hooked"))
+
+		require.NoError(t, OrganizeRepo(context.Background(), cfg, second, secondRepo))
+		assert.FileExists(t, path.Join(cfg.Destination, "upstreamuser", "upstream", "hooked"))
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, _ := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.Hooks = []string{"echo oops >&2; exit 3", "true"}
+
+		report := NewOrganizer(WithConfig(cfg)).OrganizePaths(context.Background(), []string{repoDir})
+		require.Len(t, report.Entries, 1)
+		assert.Equal(t, StatusOrganized, report.Entries[0].Status)
+		assert.Contains(t, report.Entries[0].Reason, "exit status 3: oops")
+		assert.DirExists(t, path.Join(cfg.Destination, "originuser", "origin"))
+	})
+}
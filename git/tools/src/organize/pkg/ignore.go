@@ -0,0 +1,69 @@
+package organize
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+)
+
+// IgnoreFileName is the name of the file in an input directory listing directories which should not be
+// organized. It uses gitignore syntax.
+const IgnoreFileName = ".organizeignore"
+
+// globPattern ignores directories whose name matches a glob, as used by path.Match.
+type globPattern string
+
+func (pattern globPattern) Match(p []string, _ bool) gitignore.MatchResult {
+	if len(p) == 0 {
+		return gitignore.NoMatch
+	}
+
+	if matched, _ := path.Match(string(pattern), p[len(p)-1]); matched {
+		return gitignore.Exclude
+	}
+
+	return gitignore.NoMatch
+}
+
+// NewIgnoreMatcher builds a matcher from the ignore file in dir, if one exists, and any extra glob patterns,
+// as used by path.Match, which are matched against directory names. A directory is ignored if it matches
+// either.
+func NewIgnoreMatcher(fs billy.Filesystem, dir string, patterns []string) (gitignore.Matcher, error) {
+	var parsed []gitignore.Pattern
+
+	file, err := fs.Open(path.Join(dir, IgnoreFileName))
+	if err == nil {
+		defer file.Close()
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			parsed = append(parsed, gitignore.ParsePattern(line, nil))
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("could not read ignore file '%s': %w", file.Name(), err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("could not open ignore file: %w", err)
+	}
+
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad ignore pattern '%s': %w", pattern, err)
+		}
+
+		parsed = append(parsed, globPattern(pattern))
+	}
+
+	return gitignore.NewMatcher(parsed), nil
+}
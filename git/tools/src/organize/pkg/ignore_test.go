@@ -0,0 +1,61 @@
+package organize
+
+import (
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIgnoreMatcher(t *testing.T) {
+	t.Run("NoIgnoreFile", func(t *testing.T) {
+		matcher, err := NewIgnoreMatcher(memfs.New(), "/dir", nil)
+		require.NoError(t, err)
+		assert.False(t, matcher.Match([]string{"repo"}, true))
+	})
+
+	t.Run("IgnoreFile", func(t *testing.T) {
+		fs := memfs.New()
+		require.NoError(t, util.WriteFile(fs, "/dir/"+IgnoreFileName, []byte("# scratch dirs\n\ntmp-*\nnotes/\n"), 0644))
+
+		matcher, err := NewIgnoreMatcher(fs, "/dir", nil)
+		require.NoError(t, err)
+		assert.True(t, matcher.Match([]string{"tmp-build"}, true))
+		assert.True(t, matcher.Match([]string{"notes"}, true))
+		assert.False(t, matcher.Match([]string{"repo"}, true))
+	})
+
+	t.Run("Patterns", func(t *testing.T) {
+		matcher, err := NewIgnoreMatcher(memfs.New(), "/dir", []string{"*.bak", "old-*"})
+		require.NoError(t, err)
+		assert.True(t, matcher.Match([]string{"repo.bak"}, true))
+		assert.True(t, matcher.Match([]string{"old-repo"}, true))
+		assert.False(t, matcher.Match([]string{"repo"}, true))
+	})
+
+	t.Run("PatternsAndIgnoreFile", func(t *testing.T) {
+		fs := memfs.New()
+		require.NoError(t, util.WriteFile(fs, "/dir/"+IgnoreFileName, []byte("tmp-*\n!tmp-keep\n"), 0644))
+
+		matcher, err := NewIgnoreMatcher(fs, "/dir", []string{"*.bak"})
+		require.NoError(t, err)
+		assert.True(t, matcher.Match([]string{"tmp-build"}, true))
+		assert.False(t, matcher.Match([]string{"tmp-keep"}, true))
+		assert.True(t, matcher.Match([]string{"repo.bak"}, true))
+	})
+
+	t.Run("PatternsAreGlobs", func(t *testing.T) {
+		matcher, err := NewIgnoreMatcher(memfs.New(), "/dir", []string{"!repo", "repo-[0-9]"})
+		require.NoError(t, err)
+		assert.True(t, matcher.Match([]string{"!repo"}, true))
+		assert.False(t, matcher.Match([]string{"repo"}, true))
+		assert.True(t, matcher.Match([]string{"repo-1"}, true))
+	})
+
+	t.Run("BadPattern", func(t *testing.T) {
+		_, err := NewIgnoreMatcher(memfs.New(), "/dir", []string{"repo-["})
+		assert.Error(t, err)
+	})
+}
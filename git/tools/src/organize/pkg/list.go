@@ -0,0 +1,56 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// List returns the path of every organized repo. Repos in the stage, quarantine, unsorted, and broken
+// directories are not included, nor are repos which do not match Config.Where.
+func (o *Organizer) List(ctx context.Context) ([]string, error) {
+	var repoPaths []string
+
+	_, err := o.walkOrganized(ctx, func(repoPath string) error {
+		repoPaths = append(repoPaths, repoPath)
+		return nil
+	})
+
+	return repoPaths, err
+}
+
+// ForEach runs command with `sh -c` in every repo returned by List, one repo at a time. A line naming the
+// repo followed by the command's output is written to w for each repo, and the repo's path is passed in the
+// ORGANIZE_PATH environment variable. A command failing in one repo does not stop it from being run in the
+// rest, and an error is returned for every repo it failed in.
+func (o *Organizer) ForEach(ctx context.Context, command string, w io.Writer) error {
+	repoPaths, err := o.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	for _, repoPath := range repoPaths {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+
+		fmt.Fprintf(w, "==> %s\n", repoPath)
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Dir = repoPath
+		cmd.Env = append(os.Environ(), "ORGANIZE_PATH="+repoPath)
+		cmd.Stdout = w
+		cmd.Stderr = w
+
+		if err := cmd.Run(); err != nil {
+			errs = append(errs, fmt.Errorf("command failed in repo '%s': %w", repoPath, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,248 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"gopkg.in/yaml.v3"
+)
+
+// LockFileName is the name of the lock file organize creates in Config.Destination while it is changing it.
+const LockFileName = ".organize.lock"
+
+// lockPollInterval is how often a held lock is checked while waiting for it.
+const lockPollInterval = time.Second
+
+// emptyLockRetries is how many times an empty lock file is read again before it is treated as invalid,
+// since it may still be being written.
+const emptyLockRetries = 10
+
+// staleLocks numbers the stale lock files removed by this process, so each is given a unique name.
+var staleLocks atomic.Int64
+
+// ErrLocked is returned when the destination is locked by another run.
+var ErrLocked = errors.New("destination is locked")
+
+// LockInfo describes the process holding a lock.
+type LockInfo struct {
+	PID   int       `yaml:"pid"`
+	Host  string    `yaml:"host"`
+	Start time.Time `yaml:"start"`
+}
+
+func (info LockInfo) String() string {
+	return fmt.Sprintf("pid %d on '%s' since %s", info.PID, info.Host, info.Start.Format(time.RFC3339))
+}
+
+// equal returns true if info and other describe the same lock.
+func (info LockInfo) equal(other LockInfo) bool {
+	return info.PID == other.PID && info.Host == other.Host && info.Start.Equal(other.Start)
+}
+
+// Lock is an advisory lock on a directory, held by creating a lock file in it.
+type Lock struct {
+	fs   billy.Filesystem
+	path string
+	Info LockInfo
+}
+
+// processExists returns true if a process with pid is running on this host.
+func processExists(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+// isStale returns true if info was written by a process on this host which is no longer running. Locks held
+// by other hosts can not be checked, so are never stale.
+func (info LockInfo) isStale() bool {
+	host, err := os.Hostname()
+	if err != nil || host != info.Host {
+		return false
+	}
+
+	return info.PID != os.Getpid() && !processExists(info.PID)
+}
+
+// readLock reads the lock file at p. A lock file which is still being written has an empty LockInfo.
+func readLock(fs billy.Filesystem, p string) (LockInfo, error) {
+	var info LockInfo
+
+	data, err := util.ReadFile(fs, p)
+	if err != nil {
+		return info, err
+	}
+
+	if err := yaml.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("could not parse lock file '%s': %w", p, err)
+	}
+
+	return info, nil
+}
+
+// tryLock creates the lock file at p, returning the info of the existing lock if it is already held.
+func tryLock(fs billy.Filesystem, p string, info LockInfo) (*LockInfo, error) {
+	data, err := yaml.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := fs.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		held, err := readLock(fs, p)
+		if os.IsNotExist(err) {
+			// the lock was released while it was being read
+			return &LockInfo{}, nil
+		}
+
+		return &held, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return nil, errors.Join(err, fs.Remove(p))
+	}
+
+	return nil, nil
+}
+
+// removeStaleLock removes the lock file at p if it is still the stale lock. It is first moved to a unique
+// name, so that when several runs find the same stale lock, a lock which another has acquired in the
+// meantime is put back rather than removed.
+func removeStaleLock(fs billy.Filesystem, p string, stale LockInfo) error {
+	moved := fmt.Sprintf("%s.stale-%d-%d", p, os.Getpid(), staleLocks.Add(1))
+
+	if err := fs.Rename(p, moved); os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if held, err := readLock(fs, moved); err != nil || !held.equal(stale) {
+		// the lock was acquired by another run after it was found to be stale
+		return restoreLock(fs, moved, p)
+	}
+
+	return fs.Remove(moved)
+}
+
+// restoreLock moves the lock file at moved back to p. It is never moved over a lock which yet another run
+// has acquired since, in which case it is left at moved and ErrLocked is returned.
+func restoreLock(fs billy.Filesystem, moved string, p string) error {
+	data, err := util.ReadFile(fs, moved)
+	if err != nil {
+		return err
+	}
+
+	f, err := fs.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%w: '%s' was locked by another run while removing a stale lock, the lock it replaced was moved to '%s'", ErrLocked, p, moved)
+	} else if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return fs.Remove(moved)
+}
+
+// AcquireLock locks dir, removing any stale lock left behind by a process which is no longer running. If
+// dir is locked by another process, ErrLocked is returned unless wait is set, in which case AcquireLock
+// waits until the lock is released or ctx is done.
+func AcquireLock(ctx context.Context, fs billy.Filesystem, dir string, wait bool) (*Lock, error) {
+	if err := fs.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not determine hostname: %w", err)
+	}
+
+	lock := &Lock{
+		fs:   fs,
+		path: path.Join(dir, LockFileName),
+		Info: LockInfo{PID: os.Getpid(), Host: host, Start: time.Now()},
+	}
+
+	empty := 0
+
+	for {
+		held, err := tryLock(fs, lock.path, lock.Info)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case held == nil:
+			return lock, nil
+		case held.PID == 0 && empty < emptyLockRetries:
+			empty++
+			time.Sleep(lockPollInterval / emptyLockRetries)
+			continue
+		case held.PID == 0:
+			return nil, fmt.Errorf("lock file '%s' is invalid, remove it if no other run is active", lock.path)
+		case held.isStale():
+			if err := removeStaleLock(fs, lock.path, *held); err != nil {
+				return nil, fmt.Errorf("could not remove stale lock '%s': %w", lock.path, err)
+			}
+			continue
+		case !wait:
+			return nil, fmt.Errorf("%w: '%s' is held by %s, remove it if that run is no longer active", ErrLocked, lock.path, held)
+		}
+
+		empty = 0
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// Release removes the lock file, if it is still held by this lock.
+func (lock *Lock) Release() error {
+	held, err := readLock(lock.fs, lock.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !held.equal(lock.Info) {
+		return fmt.Errorf("lock '%s' is now held by %s", lock.path, held)
+	}
+
+	return lock.fs.Remove(lock.path)
+}
+
+// Lock locks the destination so other runs can not change it at the same time.
+func (o *Organizer) Lock(ctx context.Context, wait bool) (*Lock, error) {
+	return AcquireLock(ctx, o.fs, o.config.DestinationPath(), wait)
+}
@@ -0,0 +1,192 @@
+package organize
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func writeLock(t *testing.T, fs billy.Filesystem, info LockInfo) {
+	data, err := yaml.Marshal(info)
+	require.NoError(t, err)
+	require.NoError(t, util.WriteFile(fs, "/destination/"+LockFileName, data, 0644))
+}
+
+func TestAcquireLock(t *testing.T) {
+	host, err := os.Hostname()
+	require.NoError(t, err)
+
+	t.Run("Held", func(t *testing.T) {
+		fs := memfs.New()
+
+		lock, err := AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+		assert.Equal(t, os.Getpid(), lock.Info.PID)
+		assert.Equal(t, host, lock.Info.Host)
+
+		_, err = AcquireLock(context.Background(), fs, "/destination", false)
+		assert.ErrorIs(t, err, ErrLocked)
+
+		require.NoError(t, lock.Release())
+
+		_, err = fs.Stat("/destination/" + LockFileName)
+		assert.True(t, os.IsNotExist(err))
+
+		lock, err = AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+		require.NoError(t, lock.Release())
+	})
+
+	t.Run("Stale", func(t *testing.T) {
+		fs := memfs.New()
+
+		cmd := exec.Command("true")
+		require.NoError(t, cmd.Run())
+
+		writeLock(t, fs, LockInfo{PID: cmd.Process.Pid, Host: host, Start: time.Now()})
+
+		lock, err := AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+		require.NoError(t, lock.Release())
+	})
+
+	t.Run("StaleConcurrent", func(t *testing.T) {
+		cmd := exec.Command("true")
+		require.NoError(t, cmd.Run())
+
+		for i := 0; i < 50; i++ {
+			fs := osfs.New(t.TempDir())
+			writeLock(t, fs, LockInfo{PID: cmd.Process.Pid, Host: host, Start: time.Now()})
+
+			var acquired atomic.Int64
+			var wg sync.WaitGroup
+			for j := 0; j < 2; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					_, err := AcquireLock(context.Background(), fs, "/destination", false)
+					if err == nil {
+						acquired.Add(1)
+					} else {
+						assert.ErrorIs(t, err, ErrLocked)
+					}
+				}()
+			}
+			wg.Wait()
+
+			require.Equal(t, int64(1), acquired.Load())
+
+			files, err := fs.ReadDir("/destination")
+			require.NoError(t, err)
+			assert.Len(t, files, 1)
+		}
+
+		// the run which found the lock stale after the other had already replaced it leaves the new lock alone
+		fs := memfs.New()
+		stale := LockInfo{PID: cmd.Process.Pid, Host: host, Start: time.Now()}
+		writeLock(t, fs, stale)
+
+		lock, err := AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+
+		require.NoError(t, removeStaleLock(fs, "/destination/"+LockFileName, stale))
+
+		held, err := readLock(fs, "/destination/"+LockFileName)
+		require.NoError(t, err)
+		assert.True(t, held.equal(lock.Info), held)
+		require.NoError(t, lock.Release())
+
+		files, err := fs.ReadDir("/destination")
+		require.NoError(t, err)
+		assert.Empty(t, files)
+
+		// a lock acquired by yet another run before the moved lock is put back is never replaced
+		stale = LockInfo{PID: cmd.Process.Pid, Host: host, Start: time.Now()}
+		writeLock(t, fs, stale)
+		require.NoError(t, fs.Rename("/destination/"+LockFileName, "/destination/moved"))
+
+		third, err := AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+
+		err = restoreLock(fs, "/destination/moved", "/destination/"+LockFileName)
+		assert.ErrorIs(t, err, ErrLocked)
+
+		held, err = readLock(fs, "/destination/"+LockFileName)
+		require.NoError(t, err)
+		assert.True(t, held.equal(third.Info), held)
+
+		moved, err := readLock(fs, "/destination/moved")
+		require.NoError(t, err)
+		assert.True(t, moved.equal(stale), moved)
+	})
+
+	t.Run("OtherHost", func(t *testing.T) {
+		fs := memfs.New()
+
+		writeLock(t, fs, LockInfo{PID: 1, Host: host + "-other", Start: time.Now()})
+
+		_, err := AcquireLock(context.Background(), fs, "/destination", false)
+		assert.ErrorIs(t, err, ErrLocked)
+	})
+
+	t.Run("Wait", func(t *testing.T) {
+		// memfs can not be used from several goroutines
+		fs := osfs.New(t.TempDir())
+
+		held, err := AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+
+		acquired := make(chan error)
+		go func() {
+			lock, err := AcquireLock(context.Background(), fs, "/destination", true)
+			if err == nil {
+				err = lock.Release()
+			}
+			acquired <- err
+		}()
+
+		time.Sleep(100 * time.Millisecond)
+		require.NoError(t, held.Release())
+
+		assert.NoError(t, <-acquired)
+	})
+
+	t.Run("WaitCancelled", func(t *testing.T) {
+		fs := memfs.New()
+
+		_, err := AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		_, err = AcquireLock(ctx, fs, "/destination", true)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("ReleaseReplaced", func(t *testing.T) {
+		fs := memfs.New()
+
+		lock, err := AcquireLock(context.Background(), fs, "/destination", false)
+		require.NoError(t, err)
+
+		writeLock(t, fs, LockInfo{PID: 1, Host: host + "-other", Start: time.Now()})
+
+		assert.Error(t, lock.Release())
+		_, err = fs.Stat("/destination/" + LockFileName)
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,437 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestRemote is a remote of a repo in a Manifest.
+type ManifestRemote struct {
+	Name string   `yaml:"name"`
+	URLs []string `yaml:"urls"`
+}
+
+// ManifestRepo is an organized repo in a Manifest.
+type ManifestRepo struct {
+	// Path is where the repo is organized to. Paths inside Config.Destination are relative to it, and other
+	// paths inside the user's home directory start with '~', so the manifest can be restored somewhere else.
+	Path string `yaml:"path"`
+
+	Bare bool `yaml:"bare,omitempty"`
+
+	// Branch is the branch checked out in the repo, or empty if HEAD is detached.
+	Branch string `yaml:"branch,omitempty"`
+
+	Remotes []ManifestRemote `yaml:"remotes"`
+
+	// Links are the paths of any symlinks to the repo, relative to Config.Destination the same as Path.
+	Links []string `yaml:"links,omitempty"`
+}
+
+// ErrUnsafeManifestPath is returned when restoring a manifest entry whose path is outside of every directory
+// repos are organized into.
+var ErrUnsafeManifestPath = errors.New("manifest path is outside of the destination")
+
+// Manifest describes every organized repo, so the same tree can be recreated on another machine.
+type Manifest struct {
+	Repos []ManifestRepo `yaml:"repos"`
+}
+
+// ReadManifest reads a yaml manifest from p.
+func ReadManifest(p string) (Manifest, error) {
+	var manifest Manifest
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return manifest, err
+	}
+
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return manifest, fmt.Errorf("could not parse manifest '%s': %w", p, err)
+	}
+
+	return manifest, nil
+}
+
+// isInside returns true if p is dir or inside of it.
+func isInside(dir string, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// manifestPath returns p relative to the destination if it is inside of it, or relative to '~' if it is inside
+// of the user's home directory, so the manifest can be restored on a machine with a different home directory.
+func (o *Organizer) manifestPath(p string) string {
+	destination := absPath(o.fs, o.config.DestinationPath())
+	if isInside(destination, p) {
+		rel, _ := filepath.Rel(destination, p)
+		return rel
+	}
+
+	if home, err := os.UserHomeDir(); err == nil && isInside(home, p) {
+		rel, _ := filepath.Rel(home, p)
+		return path.Join("~", rel)
+	}
+
+	return p
+}
+
+// restorePath resolves a path from a manifest. Relative paths may not escape the destination, and absolute
+// or '~' paths, which Export only writes for repos in a bare destination or host root outside of the
+// destination, must be inside the destination, the bare destination, or a host root.
+func (o *Organizer) restorePath(p string) (string, error) {
+	roots := []string{o.config.DestinationPath()}
+	if path.IsAbs(p) || strings.HasPrefix(p, "~") {
+		roots = append(roots, o.config.BareDestinationPath())
+		for host := range o.config.HostRoots {
+			roots = append(roots, o.config.HostRootPath(host))
+		}
+	}
+
+	resolved := o.config.resolve(p)
+	for _, root := range roots {
+		if isInside(absPath(o.fs, root), absPath(o.fs, resolved)) {
+			return resolved, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w: '%s'", ErrUnsafeManifestPath, p)
+}
+
+// isRepoDir returns true if p looks like a repository or a bare repository.
+func (o *Organizer) isRepoDir(p string) bool {
+	if _, err := o.fs.Lstat(path.Join(p, ".git")); err == nil {
+		return true
+	}
+
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := o.fs.Stat(path.Join(p, name)); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// manifestRepo describes the repo at repoPath.
+func (o *Organizer) manifestRepo(repoPath string) (ManifestRepo, error) {
+	entry := ManifestRepo{Path: o.manifestPath(repoPath)}
+
+	repo, err := openRepo(o.fs, repoPath)
+	if err != nil {
+		return entry, err
+	}
+
+	if _, err := repo.Worktree(); errors.Is(err, git.ErrIsBareRepository) {
+		entry.Bare = true
+	}
+
+	if head, err := repo.Head(); err == nil && head.Name().IsBranch() {
+		entry.Branch = head.Name().Short()
+	}
+
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return entry, err
+	}
+
+	for _, remote := range remotes {
+		entry.Remotes = append(entry.Remotes, ManifestRemote{
+			Name: remote.Config().Name,
+			URLs: remote.Config().URLs,
+		})
+	}
+
+	slices.SortFunc(entry.Remotes, func(a, b ManifestRemote) bool {
+		return a.Name < b.Name
+	})
+
+	return entry, nil
+}
+
+// walkOrganized calls fn with the absolute path of every repo organized into the destination, bare
+// destination, and host roots, and returns a map of the paths symlinks in those directories point to to the
+// symlinks. Repos in the stage, quarantine, unsorted, and broken directories are skipped, as are repos which
+// do not match Config.Where.
+func (o *Organizer) walkOrganized(ctx context.Context, fn func(repoPath string) error) (map[string][]string, error) {
+	filter, err := o.whereFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	roots := []string{o.config.DestinationPath(), o.config.BareDestinationPath()}
+	for host := range o.config.HostRoots {
+		roots = append(roots, o.config.HostRootPath(host))
+	}
+
+	seen := make(map[string]bool)
+
+	links := make(map[string][]string)
+
+	for _, root := range roots {
+		root = absPath(o.fs, root)
+
+		err := util.Walk(o.fs, root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				// roots which were never created have nothing to export
+				if p == root && os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				target, err := o.fs.Readlink(p)
+				if err != nil {
+					return err
+				}
+
+				if !path.IsAbs(target) {
+					target = path.Join(path.Dir(p), target)
+				}
+
+				if !slices.Contains(links[target], p) {
+					links[target] = append(links[target], p)
+				}
+
+				return nil
+			}
+
+			if !info.IsDir() {
+				return nil
+			}
+
+			// roots may be nested in each other
+			if seen[p] || p != root && o.config.isManagedPath(o.fs, p) {
+				return filepath.SkipDir
+			}
+
+			if !o.isRepoDir(p) {
+				return nil
+			}
+
+			seen[p] = true
+
+			if filter != nil && !o.matchOrganized(filter, p) {
+				return filepath.SkipDir
+			}
+
+			if err := fn(p); err != nil {
+				return err
+			}
+
+			return filepath.SkipDir
+		})
+		if err != nil {
+			return links, err
+		}
+	}
+
+	return links, nil
+}
+
+// matchOrganized returns true if the organized repo at repoPath matches filter. Repos which cannot be
+// matched are logged and treated as not matching.
+func (o *Organizer) matchOrganized(filter *Filter, repoPath string) bool {
+	repo, err := openRepo(o.fs, repoPath)
+	if err != nil {
+		o.logger.Printf("ERROR: could not open repo '%s': %s", repoPath, err)
+		return false
+	}
+
+	matched, err := filter.match(o.fs, o.config, o.aliases, repoPath, repo)
+	if err != nil {
+		o.logger.Printf("ERROR: could not evaluate where expression: %s", err)
+		return false
+	}
+
+	return matched
+}
+
+// Export describes every organized repo. Repos in the stage, quarantine, unsorted, and broken directories
+// are not included.
+func (o *Organizer) Export(ctx context.Context) (Manifest, error) {
+	var manifest Manifest
+
+	links, err := o.walkOrganized(ctx, func(repoPath string) error {
+		entry, err := o.manifestRepo(repoPath)
+		if err != nil {
+			return fmt.Errorf("could not read repo '%s': %w", repoPath, err)
+		}
+
+		manifest.Repos = append(manifest.Repos, entry)
+
+		return nil
+	})
+	if err != nil {
+		return manifest, err
+	}
+
+	for i, entry := range manifest.Repos {
+		for _, link := range links[absPath(o.fs, o.config.resolve(entry.Path))] {
+			manifest.Repos[i].Links = append(manifest.Repos[i].Links, o.manifestPath(link))
+		}
+	}
+
+	return manifest, nil
+}
+
+// cloneRemote returns the remote a repo should be cloned from, preferring primary.
+func (entry ManifestRepo) cloneRemote(primary string) (ManifestRemote, error) {
+	for _, remote := range entry.Remotes {
+		if remote.Name == primary && len(remote.URLs) > 0 {
+			return remote, nil
+		}
+	}
+
+	for _, remote := range entry.Remotes {
+		if len(remote.URLs) > 0 {
+			return remote, nil
+		}
+	}
+
+	return ManifestRemote{}, ErrNoRemotes
+}
+
+// restoreRepo clones a single repo from a manifest into repoPath and recreates its other remotes and links.
+// It returns true if the repo was restored, or false if it already exists.
+func (o *Organizer) restoreRepo(ctx context.Context, repoPath string, entry ManifestRepo) (bool, error) {
+	links := make([]string, len(entry.Links))
+	for i, link := range entry.Links {
+		var err error
+		if links[i], err = o.restorePath(link); err != nil {
+			return false, err
+		}
+	}
+
+	if _, err := o.fs.Lstat(repoPath); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	remote, err := entry.cloneRemote(o.config.primaryRemote())
+	if err != nil {
+		return false, err
+	}
+
+	worktree, err := o.fs.Chroot(repoPath)
+	if err != nil {
+		return false, err
+	}
+
+	dot := worktree
+	if entry.Bare {
+		worktree = nil
+	} else if dot, err = worktree.Chroot(".git"); err != nil {
+		return false, err
+	}
+
+	opts := &git.CloneOptions{
+		URL:        remote.URLs[0],
+		RemoteName: remote.Name,
+	}
+
+	if entry.Branch != "" {
+		opts.ReferenceName = plumbing.NewBranchReferenceName(entry.Branch)
+	}
+
+	storage := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
+
+	repo, err := git.CloneContext(ctx, storage, worktree, opts)
+	if err != nil {
+		// leave nothing half cloned behind so the restore can be retried
+		if removeErr := util.RemoveAll(o.fs, repoPath); removeErr != nil {
+			err = errors.Join(err, removeErr)
+		}
+
+		return false, fmt.Errorf("could not clone '%s': %w", remote.URLs[0], err)
+	}
+
+	// the clone only knows about the remote it was cloned from
+	for _, other := range entry.Remotes {
+		if other.Name == remote.Name && slices.Equal(other.URLs, remote.URLs) {
+			continue
+		}
+
+		if other.Name == remote.Name {
+			if err := repo.DeleteRemote(other.Name); err != nil {
+				return false, err
+			}
+		}
+
+		if _, err := repo.CreateRemote(&config.RemoteConfig{Name: other.Name, URLs: other.URLs}); err != nil {
+			return false, fmt.Errorf("could not create remote '%s': %w", other.Name, err)
+		}
+	}
+
+	var linkErrs []error
+	for _, link := range links {
+		target, err := linkTarget(o.fs, repoPath, link, o.config.RelativeLinks)
+		if err != nil {
+			linkErrs = append(linkErrs, fmt.Errorf("could not determine target for symlink '%s': %w", link, err))
+		} else if err := ensureSymlink(o.fs, target, link); err != nil {
+			linkErrs = append(linkErrs, err)
+		}
+	}
+
+	return true, errors.Join(linkErrs...)
+}
+
+// Restore clones every repo in manifest which does not already exist, using up to jobs clones at a time.
+func (o *Organizer) Restore(ctx context.Context, manifest Manifest, jobs int) *Report {
+	reports := make([]Report, len(manifest.Repos))
+
+	parallel(len(manifest.Repos), jobs, func(i int) {
+		entry := manifest.Repos[i]
+		repoPath, err := o.restorePath(entry.Path)
+		if err != nil {
+			o.logger.Printf("ERROR: could not restore repo '%s': %s", entry.Path, err)
+			reports[i].Add(entry.Path, StatusFailed, err.Error())
+			return
+		}
+
+		if ctx.Err() != nil {
+			reports[i].Add(repoPath, StatusSkipped, fmt.Sprintf("restore was interrupted: %s", ctx.Err()))
+			return
+		}
+
+		restored, err := o.restoreRepo(ctx, repoPath, entry)
+		switch {
+		case err != nil:
+			o.logger.Printf("ERROR: could not restore repo '%s': %s", repoPath, err)
+			reports[i].Add(repoPath, StatusFailed, err.Error())
+		case !restored:
+			o.logger.Printf("repo '%s' already exists", repoPath)
+			reports[i].Add(repoPath, StatusSkipped, "already exists")
+		default:
+			o.logger.Printf("restored repo '%s'", repoPath)
+			reports[i].Add(repoPath, StatusOrganized, "")
+		}
+	})
+
+	report := &Report{}
+	for i := range reports {
+		report.Merge(&reports[i])
+	}
+
+	return report
+}
@@ -0,0 +1,189 @@
+package organize
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// BareUpstream creates a bare repo with a single commit which can be cloned from.
+func BareUpstream(t *testing.T, parent string) string {
+	work := path.Join(parent, "work")
+
+	repo, err := git.PlainInit(work, false)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path.Join(work, "README.md"), []byte("upstream repo for testing"), 0644))
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	_, err = wt.Add("README.md")
+	require.NoError(t, err)
+
+	_, err = wt.Commit("initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	upstream := path.Join(parent, "upstream.git")
+	_, err = git.PlainClone(upstream, true, &git.CloneOptions{URL: work})
+	require.NoError(t, err)
+
+	return upstream
+}
+
+func TestManifest(t *testing.T) {
+	tempDir := t.TempDir()
+	upstream := BareUpstream(t, tempDir)
+
+	cfg := NewDefaultConfig()
+	cfg.Destination = path.Join(tempDir, "destination")
+
+	repoDir := path.Join(cfg.Destination, "originuser", "origin")
+	repo, err := git.PlainClone(repoDir, false, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+
+	_, err = repo.CreateRemote(remoteUpstream)
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(path.Join(cfg.Destination, "upstreamuser"), 0755))
+	require.NoError(t, os.Symlink(repoDir, path.Join(cfg.Destination, "upstreamuser", "upstream")))
+
+	_, err = git.PlainClone(path.Join(cfg.Destination, "originuser", "mirror.git"), true, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+
+	// staged repos are not organized yet
+	RepoWithRemotes(t, cfg.StagePath(), []*config.RemoteConfig{remoteOrigin})
+
+	manifest, err := NewOrganizer(WithConfig(cfg)).Export(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, Manifest{
+		Repos: []ManifestRepo{
+			{
+				Path:    "originuser/mirror.git",
+				Bare:    true,
+				Branch:  "master",
+				Remotes: []ManifestRemote{{Name: "origin", URLs: []string{upstream}}},
+			},
+			{
+				Path:   "originuser/origin",
+				Branch: "master",
+				Remotes: []ManifestRemote{
+					{Name: "origin", URLs: []string{upstream}},
+					{Name: "upstream", URLs: remoteUpstream.URLs},
+				},
+				Links: []string{"upstreamuser/upstream"},
+			},
+		},
+	}, manifest)
+
+	restoreCfg := NewDefaultConfig()
+	restoreCfg.Destination = path.Join(tempDir, "restored")
+	restoreCfg.RelativeLinks = true
+
+	o := NewOrganizer(WithConfig(restoreCfg))
+
+	report := o.Restore(context.Background(), manifest, 2)
+	assert.Equal(t, 2, report.Count(StatusOrganized))
+
+	restoredDir := path.Join(restoreCfg.Destination, "originuser", "origin")
+	assertFileContents(t, "upstream repo for testing", path.Join(restoredDir, "README.md"))
+	assert.FileExists(t, path.Join(restoreCfg.Destination, "originuser", "mirror.git", "HEAD"))
+
+	target, err := os.Readlink(path.Join(restoreCfg.Destination, "upstreamuser", "upstream"))
+	require.NoError(t, err)
+	assert.Equal(t, "../originuser/origin", target)
+
+	restored, err := NewOrganizer(WithConfig(restoreCfg)).Export(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, manifest, restored)
+
+	report = o.Restore(context.Background(), manifest, 2)
+	assert.Equal(t, 2, report.Count(StatusSkipped))
+}
+
+func TestRestoreUnsafePaths(t *testing.T) {
+	tempDir := t.TempDir()
+	upstream := BareUpstream(t, tempDir)
+
+	cfg := NewDefaultConfig()
+	cfg.Destination = path.Join(tempDir, "destination")
+	cfg.HostRoots = map[string]string{"github.com": path.Join(tempDir, "github")}
+
+	remotes := []ManifestRemote{{Name: "origin", URLs: []string{upstream}}}
+	manifest := Manifest{
+		Repos: []ManifestRepo{
+			{Path: "../escaped", Remotes: remotes},
+			{Path: "owner/../../escaped", Remotes: remotes},
+			{Path: path.Join(tempDir, "absolute"), Remotes: remotes},
+			{Path: "owner/link", Remotes: remotes, Links: []string{"../escaped-link"}},
+			{Path: path.Join(tempDir, "github", "owner", "name"), Remotes: remotes},
+		},
+	}
+
+	report := NewOrganizer(WithConfig(cfg)).Restore(context.Background(), manifest, 1)
+	assert.Equal(t, 4, report.Count(StatusFailed))
+	assert.Equal(t, 1, report.Count(StatusOrganized))
+
+	for _, entry := range report.Entries[:4] {
+		assert.Contains(t, entry.Reason, ErrUnsafeManifestPath.Error())
+	}
+
+	for _, p := range []string{"escaped", "absolute", "escaped-link", "destination/owner/link"} {
+		assert.NoFileExists(t, path.Join(tempDir, p))
+		assert.NoDirExists(t, path.Join(tempDir, p))
+	}
+
+	assert.DirExists(t, path.Join(tempDir, "github", "owner", "name"))
+}
+
+func TestManifestHomeAndPrimaryRemote(t *testing.T) {
+	tempDir := t.TempDir()
+	upstream := BareUpstream(t, tempDir)
+	fork := path.Join(tempDir, "fork.git")
+	_, err := git.PlainClone(fork, true, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+
+	home := path.Join(tempDir, "home")
+	t.Setenv("HOME", home)
+
+	cfg := NewDefaultConfig()
+	cfg.Destination = "~/src"
+	cfg.HostRoots = map[string]string{"github.com": "~/github"}
+
+	repoDir := path.Join(home, "github", "owner", "name")
+	repo, err := git.PlainClone(repoDir, false, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+	_, err = repo.CreateRemote(&config.RemoteConfig{Name: "fork", URLs: []string{fork}})
+	require.NoError(t, err)
+
+	manifest, err := NewOrganizer(WithConfig(cfg)).Export(context.Background())
+	require.NoError(t, err)
+	require.Len(t, manifest.Repos, 1)
+	assert.Equal(t, "~/github/owner/name", manifest.Repos[0].Path)
+
+	// the manifest is restored on a machine with another home directory and a different primary remote
+	t.Setenv("HOME", path.Join(tempDir, "other"))
+	cfg.PrimaryRemote = "fork"
+
+	report := NewOrganizer(WithConfig(cfg)).Restore(context.Background(), manifest, 1)
+	require.Equal(t, 1, report.Count(StatusOrganized), report.Entries)
+
+	restored, err := git.PlainOpen(path.Join(tempDir, "other", "github", "owner", "name"))
+	require.NoError(t, err)
+
+	_, err = restored.Reference(plumbing.NewRemoteReferenceName("fork", "master"), false)
+	assert.NoError(t, err)
+	_, err = restored.Reference(plumbing.NewRemoteReferenceName("origin", "master"), false)
+	assert.ErrorIs(t, err, plumbing.ErrReferenceNotFound)
+}
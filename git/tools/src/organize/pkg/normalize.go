@@ -0,0 +1,58 @@
+package organize
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization controls how the owner and name of a remote are normalized before being used as paths.
+type Normalization struct {
+	// Lowercase converts owners and names to lowercase, so that "Owner/repo" and "owner/Repo" are organized
+	// into the same place.
+	Lowercase bool `yaml:"lowercase"`
+
+	// Unicode converts owners and names to unicode normalization form C.
+	Unicode bool `yaml:"unicode"`
+
+	// ReplaceUnsafe replaces any character which is not a letter, digit, '-', '_', or '.' with '-'.
+	ReplaceUnsafe bool `yaml:"replace-unsafe"`
+}
+
+func isSafeRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' || r == '.'
+}
+
+// Apply returns the normalized form of s.
+func (n Normalization) Apply(s string) string {
+	if n.Unicode {
+		s = norm.NFC.String(s)
+	}
+
+	if n.Lowercase {
+		s = strings.ToLower(s)
+	}
+
+	if n.ReplaceUnsafe {
+		s = strings.Map(func(r rune) rune {
+			if isSafeRune(r) {
+				return r
+			}
+			return '-'
+		}, s)
+
+		// "." and ".." are safe characters but not safe names
+		if strings.Trim(s, ".") == "" {
+			s = strings.Repeat("-", len(s))
+		}
+	}
+
+	return s
+}
+
+// collisionKey returns the key used to compare paths for collisions, treating paths which would be the
+// same file on a case-insensitive or normalizing filesystem as equal.
+func collisionKey(p string) string {
+	return strings.ToLower(norm.NFC.String(p))
+}
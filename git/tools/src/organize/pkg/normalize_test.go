@@ -0,0 +1,34 @@
+package organize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizationApply(t *testing.T) {
+	t.Run("None", func(t *testing.T) {
+		assert.Equal(t, "Some Repo", Normalization{}.Apply("Some Repo"))
+	})
+
+	t.Run("Lowercase", func(t *testing.T) {
+		assert.Equal(t, "myjournal", Normalization{Lowercase: true}.Apply("MyJournal"))
+	})
+
+	t.Run("Unicode", func(t *testing.T) {
+		assert.Equal(t, "caf\u00e9", Normalization{Unicode: true}.Apply("cafe\u0301"))
+	})
+
+	t.Run("ReplaceUnsafe", func(t *testing.T) {
+		n := Normalization{ReplaceUnsafe: true}
+		assert.Equal(t, "some-repo-name", n.Apply("some repo:name"))
+		assert.Equal(t, "my_repo.go", n.Apply("my_repo.go"))
+		assert.Equal(t, "caf\u00e9", n.Apply("caf\u00e9"))
+		assert.Equal(t, "--", n.Apply(".."))
+	})
+
+	t.Run("All", func(t *testing.T) {
+		n := Normalization{Lowercase: true, Unicode: true, ReplaceUnsafe: true}
+		assert.Equal(t, "caf\u00e9-repo", n.Apply("Cafe\u0301 Repo"))
+	})
+}
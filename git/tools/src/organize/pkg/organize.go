@@ -1,34 +1,115 @@
 package organize
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os"
 	"path"
+	"strings"
 
+	"github.com/go-git/go-billy/v5"
 	git "github.com/go-git/go-git/v5"
-	"github.com/otiai10/copy"
 	"github.com/samber/lo"
+	"golang.org/x/exp/slices"
 )
 
-// getRepoPaths returns the path for the repositories origin remote and any
+// ErrNoRemotes is returned when a repo does not have any remotes it can be organized by.
+var ErrNoRemotes = errors.New("received no remotes")
+
+// repoLayout describes how a repository is laid out on disk.
+type repoLayout struct {
+	// worktree is the name of the linked worktree, or empty if the repository is not a linked worktree.
+	worktree string
+
+	// bare is true for repositories without a worktree, including mirrors.
+	bare bool
+}
+
+// dirName returns the name of the directory a repository named name should be organized into.
+func (layout repoLayout) dirName(name string) string {
+	switch {
+	case layout.bare:
+		return name + ".git"
+	case layout.worktree != "":
+		return name + "@" + layout.worktree
+	default:
+		return name
+	}
+}
+
+// root returns the top level directory a repository from host should be organized into.
+func (layout repoLayout) root(config Config, host string) string {
+	if layout.bare {
+		return config.BareDestinationPath()
+	}
+
+	return config.HostRootPath(host)
+}
+
+// getRepoLayout determines how the repository at repoPath is laid out on disk.
+func getRepoLayout(fs billy.Filesystem, repoPath string, repo *git.Repository) (repoLayout, error) {
+	if _, err := repo.Worktree(); errors.Is(err, git.ErrIsBareRepository) {
+		return repoLayout{bare: true}, nil
+	} else if err != nil {
+		return repoLayout{}, err
+	}
+
+	worktree, err := linkedWorktreeName(fs, repoPath)
+	if err != nil {
+		return repoLayout{}, fmt.Errorf("could not determine if '%s' is a linked worktree: %w", repoPath, err)
+	}
+
+	return repoLayout{worktree: worktree}, nil
+}
+
+// organizedOwnerAndName returns the owner and name of remote as they are organized, after normalizing them
+// and applying any owner alias.
+func organizedOwnerAndName(config Config, remote *git.Remote) (string, string, error) {
+	owner, name, err := getRemoteOwnerAndName(remote)
+	if err != nil {
+		return "", "", err
+	}
+
+	owner, name = config.Normalize.Apply(owner), config.Normalize.Apply(name)
+
+	if alias, found := config.OwnerAliases[owner]; found {
+		owner = alias
+	}
+
+	return owner, name, nil
+}
+
+// getRemotePath returns the path a repository should be organized into for the given remote.
+func getRemotePath(config Config, remote *git.Remote, layout repoLayout) (string, error) {
+	host, err := getRemoteHost(remote)
+	if err != nil {
+		return "", err
+	}
+
+	owner, name, err := organizedOwnerAndName(config, remote)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(layout.root(config, host), owner, layout.dirName(name)), nil
+}
+
+// getRepoPaths returns the path for the repositories primary remote and any
 // symlinks to that path that need to be created.
-func getRepoPaths(config Config, originalName string, remotes map[string]*git.Remote) (string, []string, error) {
+func getRepoPaths(config Config, originalName string, remotes map[string]*git.Remote, layout repoLayout) (string, []string, error) {
 	if len(remotes) == 0 {
-		return "", nil, fmt.Errorf("received no remotes")
+		return "", nil, ErrNoRemotes
 	}
 
-	origin, found := remotes["origin"]
+	origin, found := remotes[config.primaryRemote()]
 	if !found {
-		return "", nil, fmt.Errorf("no origin remote found")
+		return "", nil, fmt.Errorf("no %s remote found", config.primaryRemote())
 	}
 
-	owner, name, err := getRemoteOwnerAndName(origin)
+	fetchPath, err := getRemotePath(config, origin, layout)
 	if err != nil {
 		return "", nil, fmt.Errorf("could not determine remote owner and name '%s': %w", origin, err)
 	}
-
-	fetchPath := path.Join(config.Destination, owner, name)
 	if len(remotes) == 1 {
 		return fetchPath, nil, nil
 	}
@@ -39,16 +120,16 @@ func getRepoPaths(config Config, originalName string, remotes map[string]*git.Re
 	case StrategySymlink:
 		symlinks := make([]string, 0, len(remotes)-1)
 		for name, remote := range remotes {
-			if name == "origin" {
+			if name == config.primaryRemote() {
 				continue
 			}
 
-			owner, name, err := getRemoteOwnerAndName(remote)
+			link, err := getRemotePath(config, remote, layout)
 			if err != nil {
 				return "", nil, fmt.Errorf("could not determine remote owner and name '%s': %w", remote.Config().Name, err)
 			}
 
-			symlinks = append(symlinks, path.Join(config.Destination, owner, name))
+			symlinks = append(symlinks, link)
 		}
 
 		return fetchPath, symlinks, nil
@@ -67,52 +148,180 @@ func mapRemotes(remotes []*git.Remote) map[string]*git.Remote {
 	return mapped
 }
 
-func OrganizeRepo(config Config, repoPath string, repo *git.Repository) error {
-	var stagedRepo string
-	if path.IsAbs(config.Stage) {
-		stagedRepo = path.Join(config.Stage, path.Base(repoPath))
-	} else {
-		stagedRepo = path.Join(config.Destination, config.Stage, path.Base(repoPath))
+// allowedRemotes returns the remotes of repo which are allowed by config, as they will be once rewritten and
+// with any aliases in their urls resolved.
+func allowedRemotes(config Config, aliases remoteAliases, repoPath string, repo *git.Repository) ([]*git.Remote, error) {
+	remotes, err := resolvedRemotes(config, aliases, repo)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := copy.Copy(repoPath, stagedRepo); err != nil {
-		return fmt.Errorf("error staging repo '%s': %w", repoPath, err)
+	remotes = lo.Filter(remotes, func(remote *git.Remote, _ int) bool {
+		return config.IsRemoteURLAllowed(remote.Config().Name, remote.Config().URLs)
+	})
+
+	if len(remotes) == 0 {
+		return nil, fmt.Errorf("could not organize repo '%s': %w", repoPath, ErrNoRemotes)
 	}
 
-	remotes, err := repo.Remotes()
+	return remotes, nil
+}
+
+// RepoPlan describes where a repository will be organized to.
+type RepoPlan struct {
+	// RepoPath is the current path to the repository.
+	RepoPath string
+
+	// Source is the path the repository will be copied to.
+	Source string
+
+	// Links are the paths of any symlinks to Source which will be created.
+	Links []string
+
+	// Owner and Name are the owner and name of the repository's primary remote.
+	Owner string
+	Name  string
+
+	// Strategy is the remote strategy used to organize the repository.
+	Strategy MultipleRemoteStrategy
+
+	// Hooks are the shell commands to run once the repository is organized.
+	Hooks []string
+
+	// QuarantineReason is why the repository will be quarantined, or empty if it will not be.
+	QuarantineReason string
+}
+
+// Paths returns every path the plan will create.
+func (plan RepoPlan) Paths() []string {
+	return append([]string{plan.Source}, plan.Links...)
+}
+
+// PlanRepo determines where the repository at repoPath in the OS filesystem will be organized to without
+// changing anything on disk. Use Organizer.Plan for repositories in other filesystems.
+func PlanRepo(config Config, repoPath string, repo *git.Repository) (RepoPlan, error) {
+	return NewOrganizer(WithConfig(config)).Plan(context.Background(), repoPath, repo)
+}
+
+func planRepo(fs billy.Filesystem, config Config, aliases remoteAliases, repoPath string, repo *git.Repository) (RepoPlan, error) {
+	remotes, err := allowedRemotes(config, aliases, repoPath, repo)
 	if err != nil {
-		return err
+		return RepoPlan{}, err
 	}
 
-	remotes = lo.Filter(remotes, func(remote *git.Remote, _ int) bool {
-		return config.IsRemoteAllowed(remote.Config().Name)
-	})
+	layout, err := getRepoLayout(fs, repoPath, repo)
+	if err != nil {
+		return RepoPlan{}, err
+	}
+
+	mapped := mapRemotes(remotes)
+	if primary, found := mapped[config.primaryRemote()]; found {
+		config = config.forRemote(primary)
+	}
 
-	source, links, err := getRepoPaths(config, path.Base(repoPath), mapRemotes(remotes))
+	source, links, err := getRepoPaths(config, path.Base(repoPath), mapped, layout)
 	if err != nil {
-		return fmt.Errorf("could not organize repo '%s': %w", repoPath, err)
+		return RepoPlan{}, fmt.Errorf("could not organize repo '%s': %w", repoPath, err)
 	}
 
-	if err := copy.Copy(stagedRepo, source); err != nil {
-		return err
+	// getRepoPaths only succeeds when there is a primary remote with an owner and name
+	owner, name, _ := organizedOwnerAndName(config, mapped[config.primaryRemote()])
+
+	// a repo with a single remote is organized by it whatever the strategy
+	strategy := config.RemoteStrategy
+	switch {
+	case len(mapped) == 1:
+		strategy = StrategyOrigin
+	case strategy == StrategyDefault:
+		strategy = StrategyQuarantine
+	}
+
+	var quarantineReason string
+	if len(mapped) > 1 && strategy == StrategyQuarantine {
+		names := lo.Keys(mapped)
+		slices.Sort(names)
+		quarantineReason = fmt.Sprintf("repo has multiple remotes: %s", strings.Join(names, ", "))
+	}
+
+	return RepoPlan{
+		RepoPath:         repoPath,
+		Source:           source,
+		Links:            links,
+		Owner:            owner,
+		Name:             name,
+		Strategy:         strategy,
+		Hooks:            config.Hooks,
+		QuarantineReason: quarantineReason,
+	}, nil
+}
+
+// Collision is a path which more than one repository would be organized into.
+type Collision struct {
+	Path  string
+	Repos []string
+}
+
+func (collision Collision) Error() string {
+	return fmt.Sprintf("repos %s would all be organized into '%s'", strings.Join(collision.Repos, ", "), collision.Path)
+}
+
+// FindCollisions returns every path claimed by more than one repository in plans, or more than once by the
+// same repository. Paths are compared case-insensitively and after unicode normalization, since they would
+// collide on some filesystems.
+func FindCollisions(plans []RepoPlan) []Collision {
+	claims := make(map[string]*Collision)
+	var keys []string
+
+	for _, plan := range plans {
+		for _, p := range plan.Paths() {
+			key := collisionKey(path.Clean(p))
+
+			claim, found := claims[key]
+			if !found {
+				claim = &Collision{Path: p}
+				claims[key] = claim
+				keys = append(keys, key)
+			}
+
+			claim.Repos = append(claim.Repos, plan.RepoPath)
+		}
 	}
 
-	linkErrs := make([]error, 0, len(links))
-	for _, link := range links {
-		if err := os.MkdirAll(path.Dir(link), 0755); err != nil {
-			linkErrs = append(linkErrs, fmt.Errorf("could not create parent directories for symlink '%s': %w", link, err))
-		} else if err := os.Symlink(source, link); err != nil {
-			linkErrs = append(linkErrs, fmt.Errorf("could not create symlink '%s' -> '%s': %w", link, source, err))
+	var collisions []Collision
+	for _, key := range keys {
+		if claim := claims[key]; len(claim.Repos) > 1 {
+			collisions = append(collisions, *claim)
 		}
 	}
 
-	if len(linkErrs) != 0 {
-		return errors.Join(linkErrs...)
+	return collisions
+}
+
+// OrganizeRepo organizes the repository at repoPath according to config. Unlike Organizer.Execute, the repo
+// is staged before it is planned, so a staged copy is left behind if it cannot be organized.
+func OrganizeRepo(ctx context.Context, config Config, repoPath string, repo *git.Repository) error {
+	o := NewOrganizer(WithConfig(config))
+
+	if _, err := allowedRemotes(config, o.aliases, repoPath, repo); err != nil {
+		return err
+	}
+
+	if _, err := o.backup(ctx, repoPath); err != nil {
+		return err
 	}
 
-	if err := os.RemoveAll(stagedRepo); err != nil {
-		return fmt.Errorf("could not remove staged repo '%s': %w", stagedRepo, err)
+	stagedRepo, _, err := o.stage(ctx, repoPath)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	plan, err := o.Plan(ctx, repoPath, repo)
+	if err != nil {
+		return err
+	}
+
+	result, err := o.executeStaged(ctx, plan, stagedRepo)
+	result.log(o.logger, repoPath)
+
+	return err
 }
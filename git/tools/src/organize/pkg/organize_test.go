@@ -1,12 +1,13 @@
 package organize
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path"
 	"testing"
 
-	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing/cache"
@@ -16,6 +17,22 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// TestMain runs the tests with an empty HOME, so aliases in the user's git and ssh config do not change how
+// repos are organized.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "organize-home")
+	if err != nil {
+		panic(err)
+	}
+
+	os.Setenv("HOME", home)
+	os.Setenv("XDG_CONFIG_HOME", "")
+
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
 const RepoName = "MyJournal"
 const RepoBaseName = "repo"
 
@@ -74,13 +91,18 @@ func NoSymlinkExists(t *testing.T, path string, msgAndArgs ...any) bool {
 }
 
 func RepoWithRemotes(t *testing.T, parent string, remotes []*config.RemoteConfig) (string, *git.Repository) {
+	return FSRepoWithRemotes(t, NewOSFilesystem(), parent, remotes)
+}
+
+func FSRepoWithRemotes(t *testing.T, fs billy.Filesystem, parent string, remotes []*config.RemoteConfig) (string, *git.Repository) {
 	repoDir := path.Join(parent, RepoBaseName)
 
-	fs := osfs.New(repoDir)
-	dot, _ := fs.Chroot("storage")
+	wt, err := fs.Chroot(repoDir)
+	require.NoError(t, err)
+
+	dot, _ := wt.Chroot("storage")
 	st := filesystem.NewStorage(dot, cache.NewObjectLRUDefault())
 
-	wt, _ := fs.Chroot(".")
 	repo, err := git.Init(st, wt)
 	require.NoError(t, err)
 
@@ -99,6 +121,20 @@ func RepoWithRemotes(t *testing.T, parent string, remotes []*config.RemoteConfig
 	return repoDir, repo
 }
 
+func BareRepoWithRemotes(t *testing.T, parent string, remotes []*config.RemoteConfig) (string, *git.Repository) {
+	repoDir := path.Join(parent, RepoBaseName+".git")
+
+	repo, err := git.PlainInit(repoDir, true)
+	require.NoError(t, err)
+
+	for _, remote := range remotes {
+		_, err := repo.CreateRemote(remote)
+		require.NoError(t, err)
+	}
+
+	return repoDir, repo
+}
+
 func TestGetRepoPaths(t *testing.T) {
 	defaultRemotes := map[string]*git.Remote{
 		"origin": git.NewRemote(nil, &config.RemoteConfig{
@@ -115,7 +151,7 @@ func TestGetRepoPaths(t *testing.T) {
 		config := Config{
 			Destination: "/tmp",
 		}
-		source, links, err := getRepoPaths(config, "realName", map[string]*git.Remote{})
+		source, links, err := getRepoPaths(config, "realName", map[string]*git.Remote{}, repoLayout{})
 		assert.Error(t, err)
 		assert.Empty(t, source)
 		assert.Nil(t, links)
@@ -125,7 +161,7 @@ func TestGetRepoPaths(t *testing.T) {
 		config := Config{
 			Destination: "/tmp",
 		}
-		source, links, err := getRepoPaths(config, "realName", map[string]*git.Remote{})
+		source, links, err := getRepoPaths(config, "realName", map[string]*git.Remote{}, repoLayout{})
 		assert.Error(t, err)
 		assert.Empty(t, source)
 		assert.Nil(t, links)
@@ -136,25 +172,125 @@ func TestGetRepoPaths(t *testing.T) {
 			Destination:    "/tmp",
 			RemoteStrategy: StrategySymlink,
 		}
-		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes)
+		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes, repoLayout{})
 		require.NoError(t, err)
 		assert.Equal(t, "/tmp/joshmeranda/MyJournal", source)
 		assert.Equal(t, []string{"/tmp/some-org/MyJournal"}, links)
 	})
 
+	t.Run("Bare", func(t *testing.T) {
+		cfg := Config{
+			Destination:     "/tmp",
+			BareDestination: "/bare",
+			RemoteStrategy:  StrategySymlink,
+		}
+		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes, repoLayout{bare: true})
+		require.NoError(t, err)
+		assert.Equal(t, "/bare/joshmeranda/MyJournal.git", source)
+		assert.Equal(t, []string{"/bare/some-org/MyJournal.git"}, links)
+	})
+
+	t.Run("LinkedWorktree", func(t *testing.T) {
+		cfg := Config{
+			Destination:    "/tmp",
+			RemoteStrategy: StrategyOrigin,
+		}
+		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes, repoLayout{worktree: "feature"})
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/joshmeranda/MyJournal@feature", source)
+		assert.Nil(t, links)
+	})
+
+	t.Run("Normalize", func(t *testing.T) {
+		cfg := Config{
+			Destination:    "/tmp",
+			RemoteStrategy: StrategySymlink,
+			Normalize:      Normalization{Lowercase: true},
+		}
+		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes, repoLayout{})
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/joshmeranda/myjournal", source)
+		assert.Equal(t, []string{"/tmp/some-org/myjournal"}, links)
+	})
+
+	t.Run("HostRoots", func(t *testing.T) {
+		cfg := Config{
+			Destination:    "/tmp",
+			RemoteStrategy: StrategySymlink,
+			HostRoots:      map[string]string{"github.com": "/gh"},
+		}
+		remotes := map[string]*git.Remote{
+			"origin": defaultRemotes["origin"],
+			"upstream": git.NewRemote(nil, &config.RemoteConfig{
+				Name: "upstream",
+				URLs: []string{"git@gitlab.internal:some-org/MyJournal.git"},
+			}),
+		}
+		source, links, err := getRepoPaths(cfg, "realName", remotes, repoLayout{})
+		require.NoError(t, err)
+		assert.Equal(t, "/gh/joshmeranda/MyJournal", source)
+		assert.Equal(t, []string{"/tmp/some-org/MyJournal"}, links)
+	})
+
+	t.Run("OwnerAliases", func(t *testing.T) {
+		cfg := Config{
+			Destination:    "/tmp",
+			RemoteStrategy: StrategySymlink,
+			OwnerAliases:   map[string]string{"some-org": "org"},
+		}
+		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes, repoLayout{})
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/joshmeranda/MyJournal", source)
+		assert.Equal(t, []string{"/tmp/org/MyJournal"}, links)
+	})
+
 	t.Run("StrategyQuarantine", func(t *testing.T) {
 		cfg := Config{
 			Destination:    "/tmp",
 			Quarantine:     "/tmp/quarantine",
 			RemoteStrategy: StrategyQuarantine,
 		}
-		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes)
+		source, links, err := getRepoPaths(cfg, "realName", defaultRemotes, repoLayout{})
 		require.NoError(t, err)
 		assert.Equal(t, "/tmp/quarantine/realName", source)
 		assert.Nil(t, links)
 	})
 }
 
+func TestFindCollisions(t *testing.T) {
+	t.Run("NoCollisions", func(t *testing.T) {
+		plans := []RepoPlan{
+			{RepoPath: "/in/a", Source: "/out/owner/a"},
+			{RepoPath: "/in/b", Source: "/out/owner/b", Links: []string{"/out/other/b"}},
+		}
+		assert.Empty(t, FindCollisions(plans))
+	})
+
+	t.Run("SameSource", func(t *testing.T) {
+		plans := []RepoPlan{
+			{RepoPath: "/in/a", Source: "/out/owner/repo"},
+			{RepoPath: "/in/b", Source: "/out/owner/repo"},
+		}
+		assert.Equal(t, []Collision{{Path: "/out/owner/repo", Repos: []string{"/in/a", "/in/b"}}}, FindCollisions(plans))
+	})
+
+	t.Run("DifferentCase", func(t *testing.T) {
+		plans := []RepoPlan{
+			{RepoPath: "/in/a", Source: "/out/Owner/repo"},
+			{RepoPath: "/in/b", Source: "/out/owner/Repo"},
+		}
+		assert.Equal(t, []Collision{{Path: "/out/Owner/repo", Repos: []string{"/in/a", "/in/b"}}}, FindCollisions(plans))
+	})
+
+	t.Run("LinkAndSource", func(t *testing.T) {
+		plans := []RepoPlan{
+			{RepoPath: "/in/a", Source: "/out/owner/repo"},
+			{RepoPath: "/in/b", Source: "/out/fork/repo", Links: []string{"/out/owner/repo"}},
+		}
+		assert.Equal(t, []Collision{{Path: "/out/owner/repo", Repos: []string{"/in/a", "/in/b"}}}, FindCollisions(plans))
+	})
+}
+
 func TestOrganizeRepo(t *testing.T) {
 	setup := func(t *testing.T) (func(), string) {
 		tempDir, err := os.MkdirTemp("", "")
@@ -179,7 +315,7 @@ func TestOrganizeRepo(t *testing.T) {
 		config := NewDefaultConfig()
 		config.Destination = path.Join(tempDir, "destination")
 
-		require.NoError(t, OrganizeRepo(config, repoDir, repo))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
 		assert.DirExists(t, path.Join(config.Destination, "originuser", "origin"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", ".git"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", "README.md"))
@@ -195,7 +331,7 @@ func TestOrganizeRepo(t *testing.T) {
 		config.Destination = path.Join(tempDir, "destination")
 		config.ExcludeRemotes = []string{"upstream", "mirror"}
 
-		require.NoError(t, OrganizeRepo(config, repoDir, repo))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
 		assert.DirExists(t, path.Join(config.Destination, "originuser", "origin"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", ".git"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", "README.md"))
@@ -211,12 +347,27 @@ func TestOrganizeRepo(t *testing.T) {
 		config.Destination = path.Join(tempDir, "destination")
 		config.IncludeRemotes = []string{"origin"}
 
-		require.NoError(t, OrganizeRepo(config, repoDir, repo))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
 		assert.DirExists(t, path.Join(config.Destination, "originuser", "origin"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", ".git"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", "README.md"))
 	})
 
+	t.Run("TestMultipleRemotesExcludeURLPattern", func(t *testing.T) {
+		cleanup, tempDir := setup(t)
+		defer cleanup()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin, remoteMirror, remoteUpstream})
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+		config.ExcludeRemotes = []string{"url:re:^https://"}
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		assert.DirExists(t, path.Join(config.Destination, "originuser", "origin"))
+		assert.NoDirExists(t, path.Join(config.QuarantinePath(), RepoBaseName))
+	})
+
 	t.Run("TestMultipleRemotesStrategyOrigin", func(t *testing.T) {
 		cleanup, tempDir := setup(t)
 		defer cleanup()
@@ -227,7 +378,7 @@ func TestOrganizeRepo(t *testing.T) {
 		config.Destination = path.Join(tempDir, "destination")
 		config.RemoteStrategy = StrategyOrigin
 
-		require.NoError(t, OrganizeRepo(config, repoDir, repo))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
 		assert.DirExists(t, path.Join(config.Destination, "originuser", "origin"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", ".git"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", "README.md"))
@@ -243,7 +394,7 @@ func TestOrganizeRepo(t *testing.T) {
 		config.Destination = path.Join(tempDir, "destination")
 		config.RemoteStrategy = StrategySymlink
 
-		require.NoError(t, OrganizeRepo(config, repoDir, repo))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
 
 		assert.DirExists(t, path.Join(config.Destination, "originuser", "origin"))
 		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin", ".git"))
@@ -258,6 +409,69 @@ func TestOrganizeRepo(t *testing.T) {
 		assert.FileExists(t, path.Join(config.Destination, "mirroruser", "mirror", "README.md"))
 	})
 
+	t.Run("TestMultipleRemotesStrategySymlinkTwice", func(t *testing.T) {
+		cleanup, tempDir := setup(t)
+		defer cleanup()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin, remoteMirror, remoteUpstream})
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+		config.RemoteStrategy = StrategySymlink
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+
+		symlinkExists(t, path.Join(config.Destination, "upstreamuser", "upstream"))
+		symlinkExists(t, path.Join(config.Destination, "mirroruser", "mirror"))
+	})
+
+	t.Run("TestOrganizeTwiceWithSymlinks", func(t *testing.T) {
+		cleanup, tempDir := setup(t)
+		defer cleanup()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+		require.NoError(t, os.Symlink("README.md", path.Join(repoDir, "link")))
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+
+		require.NoError(t, os.Remove(path.Join(repoDir, "link")))
+		require.NoError(t, os.Symlink("storage", path.Join(repoDir, "link")))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+
+		target, err := os.Readlink(path.Join(config.Destination, "originuser", "origin", "link"))
+		require.NoError(t, err)
+		assert.Equal(t, "storage", target)
+	})
+
+	t.Run("TestMultipleRemotesStrategySymlinkRelative", func(t *testing.T) {
+		cleanup, tempDir := setup(t)
+		defer cleanup()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin, remoteUpstream})
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+		config.RemoteStrategy = StrategySymlink
+		config.RelativeLinks = true
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+
+		link := path.Join(config.Destination, "upstreamuser", "upstream")
+		symlinkExists(t, link)
+
+		target, err := os.Readlink(link)
+		require.NoError(t, err)
+		assert.Equal(t, "../originuser/origin", target)
+
+		moved := path.Join(tempDir, "moved")
+		require.NoError(t, os.Rename(config.Destination, moved))
+		assert.FileExists(t, path.Join(moved, "upstreamuser", "upstream", "README.md"))
+	})
+
 	t.Run("TestMultipleRemotesStrategyQuarantine", func(t *testing.T) {
 		cleanup, tempDir := setup(t)
 		defer cleanup()
@@ -268,7 +482,7 @@ func TestOrganizeRepo(t *testing.T) {
 		config.Destination = path.Join(tempDir, "destination")
 		config.RemoteStrategy = StrategyQuarantine
 
-		require.NoError(t, OrganizeRepo(config, repoDir, repo))
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
 
 		assert.NoDirExists(t, path.Join(config.Destination, "originuser", "origin"))
 		assert.NoFileExists(t, path.Join(config.Destination, "originuser", "origin", ".git"))
@@ -287,6 +501,59 @@ func TestOrganizeRepo(t *testing.T) {
 		assert.FileExists(t, path.Join(config.Destination, config.Quarantine, RepoBaseName, "README.md"))
 	})
 
+	t.Run("TestBare", func(t *testing.T) {
+		cleanup, tempDir := setup(t)
+		defer cleanup()
+
+		repoDir, repo := BareRepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+		config.BareDestination = path.Join(tempDir, "bare")
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		assert.NoDirExists(t, path.Join(config.Destination, "originuser"))
+		assert.DirExists(t, path.Join(config.BareDestination, "originuser", "origin.git"))
+		assert.FileExists(t, path.Join(config.BareDestination, "originuser", "origin.git", "HEAD"))
+		assert.FileExists(t, path.Join(config.BareDestination, "originuser", "origin.git", "config"))
+	})
+
+	t.Run("TestMirror", func(t *testing.T) {
+		cleanup, tempDir := setup(t)
+		defer cleanup()
+
+		repoDir, repo := BareRepoWithRemotes(t, tempDir, []*config.RemoteConfig{{
+			Name:  "origin",
+			URLs:  []string{"git@github.com:originuser/origin.git"},
+			Fetch: []config.RefSpec{"+refs/*:refs/*"},
+		}})
+
+		cfg, err := repo.Config()
+		require.NoError(t, err)
+		cfg.Raw.Section("remote").Subsection("origin").SetOption("mirror", "true")
+		require.NoError(t, repo.SetConfig(cfg))
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		assert.DirExists(t, path.Join(config.Destination, "originuser", "origin.git"))
+		assert.FileExists(t, path.Join(config.Destination, "originuser", "origin.git", "HEAD"))
+	})
+
+	t.Run("TestNoRemotes", func(t *testing.T) {
+		cleanup, tempDir := setup(t)
+		defer cleanup()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{})
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+
+		require.ErrorIs(t, OrganizeRepo(context.Background(), config, repoDir, repo), ErrNoRemotes)
+		assert.NoDirExists(t, path.Join(config.Destination, ".stage", RepoBaseName))
+	})
+
 	t.Run("TestBadRemote", func(t *testing.T) {
 		cleanup, tempDir := setup(t)
 		defer cleanup()
@@ -296,7 +563,7 @@ func TestOrganizeRepo(t *testing.T) {
 		config := NewDefaultConfig()
 		config.Destination = path.Join(tempDir, "destination")
 
-		require.Error(t, OrganizeRepo(config, repoDir, repo))
+		require.Error(t, OrganizeRepo(context.Background(), config, repoDir, repo))
 		assert.DirExists(t, path.Join(config.Destination, ".stage", RepoBaseName))
 		assert.NoDirExists(t, path.Join(config.Destination, "badRemote"))
 	})
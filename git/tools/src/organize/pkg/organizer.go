@@ -0,0 +1,576 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+)
+
+// PreHook is called before a repo is organized. Returning an error prevents the repo from being organized.
+type PreHook func(ctx context.Context, plan RepoPlan) error
+
+// PostHook is called after a repo is organized, with the error encountered if any.
+type PostHook func(ctx context.Context, plan RepoPlan, err error)
+
+// Organizer organizes repos according to its Config.
+type Organizer struct {
+	config    Config
+	fs        billy.Filesystem
+	logger    *log.Logger
+	aliases   remoteAliases
+	preHooks  []PreHook
+	postHooks []PostHook
+	progress  Progress
+}
+
+// Option configures an Organizer.
+type Option func(*Organizer)
+
+// WithConfig sets the config used to organize repos.
+func WithConfig(config Config) Option {
+	return func(o *Organizer) {
+		o.config = config
+	}
+}
+
+// WithFilesystem sets the filesystem repos are read from and organized into. By default the OS filesystem is
+// used.
+func WithFilesystem(fs billy.Filesystem) Option {
+	return func(o *Organizer) {
+		o.fs = fs
+	}
+}
+
+// WithLogger sets the logger progress and errors are written to. By default nothing is logged.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *Organizer) {
+		o.logger = logger
+	}
+}
+
+// WithPreHook adds a hook to be called before each repo is organized.
+func WithPreHook(hook PreHook) Option {
+	return func(o *Organizer) {
+		o.preHooks = append(o.preHooks, hook)
+	}
+}
+
+// WithPostHook adds a hook to be called after each repo is organized.
+func WithPostHook(hook PostHook) Option {
+	return func(o *Organizer) {
+		o.postHooks = append(o.postHooks, hook)
+	}
+}
+
+// NewOrganizer creates an Organizer using NewDefaultConfig unless another config is given. If the user's git
+// or ssh config can not be loaded, a warning is logged and the aliases in it are ignored.
+func NewOrganizer(opts ...Option) *Organizer {
+	o := &Organizer{
+		config:   NewDefaultConfig(),
+		fs:       NewOSFilesystem(),
+		logger:   log.New(io.Discard, "", 0),
+		progress: nopProgress{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	aliases, err := loadUserAliases(o.fs)
+	if err != nil {
+		o.logger.Printf("WARNING: ignoring remote aliases: %s", err)
+	}
+	o.aliases = aliases
+
+	return o
+}
+
+// Config returns the config used by the organizer.
+func (o *Organizer) Config() Config {
+	return o.config
+}
+
+// Plan determines where the repository at repoPath will be organized to without changing anything on disk.
+func (o *Organizer) Plan(_ context.Context, repoPath string, repo *git.Repository) (RepoPlan, error) {
+	return planRepo(o.fs, o.config, o.aliases, repoPath, repo)
+}
+
+// exists returns true if p exists, even if it is a dangling symlink.
+func (o *Organizer) exists(p string) bool {
+	_, err := o.fs.Lstat(p)
+	return err == nil
+}
+
+// removePartial removes dst after it was only partially written, and returns err along with any error
+// encountered removing it.
+func removePartial(fs billy.Filesystem, dst string, err error) error {
+	if removeErr := util.RemoveAll(fs, dst); removeErr != nil {
+		return errors.Join(err, fmt.Errorf("could not roll back '%s': %w", dst, removeErr))
+	}
+
+	return err
+}
+
+// copyDir is copyDir, but reports the bytes copied to the organizer's Progress.
+func (o *Organizer) copyDir(ctx context.Context, src string, dst string) ([]string, error) {
+	return (&copier{ctx: ctx, fs: o.fs, copied: o.progress.Copied}).copy(src, dst)
+}
+
+// stage copies the repo at repoPath into the stage directory, and returns the path to the staged copy along
+// with any file metadata which could not be preserved.
+func (o *Organizer) stage(ctx context.Context, repoPath string) (string, []string, error) {
+	stagedRepo := path.Join(o.config.StagePath(), path.Base(repoPath))
+	existed := o.exists(stagedRepo)
+
+	warnings, err := o.copyDir(ctx, repoPath, stagedRepo)
+	if err != nil {
+		err = fmt.Errorf("error staging repo '%s': %w", repoPath, err)
+		if !existed {
+			err = removePartial(o.fs, stagedRepo, err)
+		}
+
+		return "", nil, err
+	}
+
+	return stagedRepo, warnings, nil
+}
+
+// executed is the outcome of organizing a repo.
+type executed struct {
+	// warnings describes any file metadata which could not be preserved, remotes which could not be rewritten,
+	// or hooks which failed.
+	warnings []string
+
+	// changes describes each change made to the repo's config by Config.RewriteRemotes.
+	changes []string
+}
+
+// siblingPath returns the path of a hidden file beside p, named after p and suffix.
+func siblingPath(p string, suffix string) string {
+	return path.Join(path.Dir(p), fmt.Sprintf(".%s.%s", path.Base(p), suffix))
+}
+
+// replace moves src to dst, replacing anything already at dst. The previous dst is moved aside and only
+// removed once src is in its place, and is moved back if src could not be.
+func (o *Organizer) replace(src string, dst string) error {
+	if !o.exists(dst) {
+		return o.fs.Rename(src, dst)
+	}
+
+	previous := siblingPath(dst, "replaced")
+	if err := util.RemoveAll(o.fs, previous); err != nil {
+		return err
+	}
+
+	if err := o.fs.Rename(dst, previous); err != nil {
+		return err
+	}
+
+	if err := o.fs.Rename(src, dst); err != nil {
+		if restoreErr := o.fs.Rename(previous, dst); restoreErr != nil {
+			err = errors.Join(err, fmt.Errorf("could not restore '%s': %w", dst, restoreErr))
+		}
+
+		return err
+	}
+
+	return util.RemoveAll(o.fs, previous)
+}
+
+// executeStaged organizes a repo which has already been staged, then runs the plan's hooks. The staged copy is
+// only removed if no error is encountered, and an existing organized copy is only replaced once the new copy
+// is complete and verified. Any file metadata which could not be preserved, hooks which failed, and changes
+// made to the repo's remotes are returned.
+func (o *Organizer) executeStaged(ctx context.Context, plan RepoPlan, stagedRepo string) (executed, error) {
+	// the repo is copied beside plan.Source so an interrupted or mismatched copy never touches an organized
+	// copy from a previous run
+	copyPath := siblingPath(plan.Source, "organizing")
+	if err := util.RemoveAll(o.fs, copyPath); err != nil {
+		return executed{}, err
+	}
+
+	// the organized repo is checked before its links are relocated, which changes files in git directories
+	// outside of .git, and the staged copy is kept for recovery if it does not match the original
+	warnings, err := o.copyDir(ctx, stagedRepo, copyPath)
+	if err == nil {
+		err = verifyCopy(o.fs, plan.RepoPath, copyPath, o.config.VerifyConnectivity)
+	}
+
+	if err == nil {
+		err = o.replace(copyPath, plan.Source)
+	}
+
+	if err != nil {
+		return executed{}, removePartial(o.fs, copyPath, err)
+	}
+
+	if err := RelocateGitLinks(o.fs, plan.RepoPath, plan.Source, o.config.RelinkWorktrees); err != nil {
+		return executed{}, fmt.Errorf("could not relocate worktree and submodule links for '%s': %w", plan.RepoPath, err)
+	}
+
+	// remotes are rewritten once the organized repo has been verified, as they are expected to differ from the
+	// original's, and failing to rewrite them does not stop the repo from being organized
+	rewritten, err := o.rewriteRemotes(plan.Source)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("could not rewrite remotes: %s", err))
+	}
+	warnings = append(warnings, rewritten.warnings...)
+
+	if plan.QuarantineReason != "" {
+		if err := writeBucketMetadata(o.fs, plan.Source, plan.RepoPath, plan.QuarantineReason); err != nil {
+			return executed{}, err
+		}
+	}
+
+	linkErrs := make([]error, 0, len(plan.Links))
+	for _, link := range plan.Links {
+		target, err := linkTarget(o.fs, plan.Source, link, o.config.RelativeLinks)
+		if err != nil {
+			linkErrs = append(linkErrs, fmt.Errorf("could not determine target for symlink '%s': %w", link, err))
+		} else if err := ensureSymlink(o.fs, target, link); err != nil {
+			linkErrs = append(linkErrs, err)
+		}
+	}
+
+	if len(linkErrs) != 0 {
+		return executed{}, errors.Join(linkErrs...)
+	}
+
+	if err := util.RemoveAll(o.fs, stagedRepo); err != nil {
+		return executed{}, fmt.Errorf("could not remove staged repo '%s': %w", stagedRepo, err)
+	}
+
+	// the repo is organized even if its hooks fail, so failures are reported along with any warnings
+	for _, err := range o.runCommandHooks(ctx, plan) {
+		warnings = append(warnings, err.Error())
+	}
+
+	return executed{warnings: warnings, changes: rewritten.changes}, nil
+}
+
+// log logs the warnings and changes of the repo at repoPath.
+func (result executed) log(logger *log.Logger, repoPath string) {
+	for _, warning := range result.warnings {
+		logger.Printf("WARNING: repo '%s': %s", repoPath, warning)
+	}
+
+	for _, change := range result.changes {
+		logger.Printf("repo '%s': %s", repoPath, change)
+	}
+}
+
+// Execute organizes a repo according to plan, calling any hooks before and after. If ctx is done before the
+// repo is completely copied, anything copied so far is removed. Once the copy is complete, the repo is
+// organized regardless of ctx.
+func (o *Organizer) Execute(ctx context.Context, plan RepoPlan) error {
+	result, err := o.execute(ctx, plan)
+	result.log(o.logger, plan.RepoPath)
+
+	return err
+}
+
+// execute is Execute, but returns any warnings and changes instead of logging them.
+func (o *Organizer) execute(ctx context.Context, plan RepoPlan) (executed, error) {
+	for _, hook := range o.preHooks {
+		if err := hook(ctx, plan); err != nil {
+			err = fmt.Errorf("pre hook failed for repo '%s': %w", plan.RepoPath, err)
+			o.runPostHooks(ctx, plan, err)
+			return executed{}, err
+		}
+	}
+
+	_, err := o.backup(ctx, plan.RepoPath)
+
+	var stagedRepo string
+	var result executed
+	if err == nil {
+		stagedRepo, result.warnings, err = o.stage(ctx, plan.RepoPath)
+	}
+
+	if err == nil {
+		var staged executed
+		staged, err = o.executeStaged(ctx, plan, stagedRepo)
+		result.warnings = append(result.warnings, staged.warnings...)
+		result.changes = staged.changes
+
+		// the original is untouched, so the staged copy is not needed to recover an interrupted repo
+		if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			err = removePartial(o.fs, stagedRepo, err)
+		}
+	}
+
+	o.runPostHooks(ctx, plan, err)
+
+	return result, err
+}
+
+func (o *Organizer) runPostHooks(ctx context.Context, plan RepoPlan, err error) {
+	for _, hook := range o.postHooks {
+		hook(ctx, plan, err)
+	}
+}
+
+// listDir returns the paths of all directories in dir which should be organized.
+func (o *Organizer) listDir(report *Report, dir string) ([]string, error) {
+	items, err := o.fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := NewIgnoreMatcher(o.fs, dir, o.config.Ignore)
+	if err != nil {
+		return nil, err
+	}
+
+	var repoPaths []string
+	for _, item := range items {
+		if item.IsDir() {
+			repoPath := path.Join(dir, item.Name())
+
+			if o.config.isManagedPath(o.fs, repoPath) {
+				continue
+			}
+
+			if matcher.Match([]string{item.Name()}, true) {
+				o.logger.Printf("skipping ignored dir '%s'", repoPath)
+				report.Add(repoPath, StatusSkipped, "matched ignore rules")
+				continue
+			}
+
+			repoPaths = append(repoPaths, repoPath)
+		}
+	}
+
+	return repoPaths, nil
+}
+
+// moveToBucket moves repoPath into bucket, or records the original error if no bucket is configured.
+func (o *Organizer) moveToBucket(report *Report, bucket string, repoPath string, reason string) {
+	if bucket == "" {
+		report.Add(repoPath, StatusFailed, reason)
+		return
+	}
+
+	target, err := MoveToBucket(o.fs, bucket, repoPath, reason)
+	if err != nil {
+		o.logger.Printf("ERROR: could not move '%s' to bucket: %s", repoPath, err)
+		report.Add(repoPath, StatusFailed, fmt.Sprintf("%s: %s", reason, err))
+		return
+	}
+
+	o.logger.Printf("moved '%s' to '%s'", repoPath, target)
+	report.Add(repoPath, StatusMoved, fmt.Sprintf("moved to '%s': %s", target, reason))
+}
+
+// candidate is a directory which will be organized.
+type candidate struct {
+	path    string
+	repo    *git.Repository
+	plan    RepoPlan
+	openErr error
+	planErr error
+}
+
+// organizeCandidate organizes c, and returns true if it was interrupted and rolled back.
+func (o *Organizer) organizeCandidate(ctx context.Context, report *Report, c candidate) bool {
+	switch {
+	case errors.Is(c.openErr, git.ErrRepositoryNotExists):
+		o.logger.Printf("'%s' is not a repo", c.path)
+		o.moveToBucket(report, o.config.UnsortedPath(), c.path, "not a git repository")
+	case c.openErr != nil:
+		o.logger.Printf("ERROR: could not open repo '%s': %s", c.path, c.openErr)
+		o.moveToBucket(report, o.config.BrokenPath(), c.path, fmt.Sprintf("could not open repo: %s", c.openErr))
+	case errors.Is(c.planErr, ErrNoRemotes):
+		o.logger.Printf("repo '%s' has no remotes", c.path)
+		o.moveToBucket(report, o.config.UnsortedPath(), c.path, "repository has no remotes")
+	case c.planErr != nil:
+		o.logger.Printf("ERROR: could not organize repo '%s': %s", path.Base(c.path), c.planErr)
+		report.Add(c.path, StatusFailed, c.planErr.Error())
+	default:
+		if result, err := o.execute(ctx, c.plan); err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			o.logger.Printf("organizing repo '%s' was interrupted, any changes were rolled back", c.path)
+			report.Add(c.path, StatusSkipped, fmt.Sprintf("organize was interrupted: %s", ctx.Err()))
+			return true
+		} else if err != nil {
+			o.logger.Printf("ERROR: could not organize repo '%s': %s", path.Base(c.path), err)
+			report.Add(c.path, StatusFailed, err.Error())
+		} else {
+			result.log(o.logger, c.path)
+
+			o.logger.Printf("organized repo '%s'", c.path)
+
+			report.AddChanges(c.path, StatusOrganized, strings.Join(result.warnings, "; "), result.changes)
+		}
+	}
+
+	return false
+}
+
+// repoSizes returns how many bytes will be copied to organize each candidate which will be copied, which is
+// twice its size as it is copied into the stage before being copied into place. Sizes are only determined if
+// progress is being reported.
+func (o *Organizer) repoSizes(candidates []candidate, colliding map[string]Collision) map[string]int64 {
+	sizes := make(map[string]int64)
+	if _, ok := o.progress.(nopProgress); ok {
+		return sizes
+	}
+
+	for _, c := range candidates {
+		if _, found := colliding[c.path]; found || c.openErr != nil || c.planErr != nil {
+			continue
+		}
+
+		size, err := dirSize(o.fs, c.path)
+		if err != nil {
+			o.logger.Printf("WARNING: could not determine size of '%s': %s", c.path, err)
+		}
+
+		sizes[c.path] = 2 * size
+	}
+
+	return sizes
+}
+
+// whereFilter parses Config.Where, or returns nil if it is empty.
+func (o *Organizer) whereFilter() (*Filter, error) {
+	if o.config.Where == "" {
+		return nil, nil
+	}
+
+	return ParseFilter(o.config.Where)
+}
+
+// OrganizePaths organizes each directory in repoPaths. Every repo is planned before anything is moved, and
+// any repos which would collide with each other are left in place. If Config.Where is set, only the repos it
+// matches are organized, and any directories which are not repos are left in place.
+func (o *Organizer) OrganizePaths(ctx context.Context, repoPaths []string) *Report {
+	report := &Report{}
+
+	filter, err := o.whereFilter()
+	if err != nil {
+		for _, repoPath := range repoPaths {
+			report.Add(repoPath, StatusFailed, err.Error())
+		}
+
+		return report
+	}
+
+	candidates := make([]candidate, 0, len(repoPaths))
+	plans := make([]RepoPlan, 0, len(repoPaths))
+
+	for _, repoPath := range repoPaths {
+		c := candidate{path: repoPath}
+
+		c.repo, c.openErr = openRepo(o.fs, repoPath)
+
+		if filter != nil {
+			if c.openErr != nil {
+				report.Add(repoPath, StatusSkipped, fmt.Sprintf("could not be matched against where expression: %s", c.openErr))
+				continue
+			}
+
+			if matched, err := filter.match(o.fs, o.config, o.aliases, repoPath, c.repo); err != nil {
+				o.logger.Printf("ERROR: %s", err)
+				report.Add(repoPath, StatusFailed, fmt.Sprintf("could not evaluate where expression: %s", err))
+				continue
+			} else if !matched {
+				report.Add(repoPath, StatusSkipped, "did not match where expression")
+				continue
+			}
+		}
+
+		if c.openErr == nil {
+			c.plan, c.planErr = o.Plan(ctx, repoPath, c.repo)
+		}
+
+		if c.openErr == nil && c.planErr == nil {
+			plans = append(plans, c.plan)
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	colliding := make(map[string]Collision)
+	for _, collision := range FindCollisions(plans) {
+		o.logger.Printf("ERROR: %s", collision)
+		for _, repoPath := range collision.Repos {
+			colliding[repoPath] = collision
+		}
+	}
+
+	sizes := o.repoSizes(candidates, colliding)
+
+	var total int64
+	for _, size := range sizes {
+		total += size
+	}
+
+	o.progress.Start(len(candidates)-len(colliding), total)
+	defer o.progress.Finish()
+
+	// remaining are the repos which were not organized because of an interruption
+	var remaining []string
+
+	for _, c := range candidates {
+		if ctx.Err() != nil {
+			report.Add(c.path, StatusSkipped, fmt.Sprintf("organize was interrupted: %s", ctx.Err()))
+			remaining = append(remaining, c.path)
+			continue
+		}
+
+		if collision, found := colliding[c.path]; found {
+			report.Add(c.path, StatusFailed, collision.Error())
+			continue
+		}
+
+		o.progress.StartRepo(c.path, sizes[c.path])
+		interrupted := o.organizeCandidate(ctx, report, c)
+		o.progress.FinishRepo(c.path)
+
+		if interrupted {
+			remaining = append(remaining, c.path)
+		}
+	}
+
+	if len(remaining) != 0 {
+		if err := o.writeResumeState(remaining); err != nil {
+			o.logger.Printf("ERROR: could not write resume file: %s", err)
+		} else {
+			o.logger.Printf("%d repos were not organized, run again with --resume to organize them", len(remaining))
+		}
+	}
+
+	return report
+}
+
+// OrganizeAll organizes every directory in each of dirs. Dirs which cannot be listed are skipped, and their
+// errors returned once everything else has been organized.
+func (o *Organizer) OrganizeAll(ctx context.Context, dirs ...string) (*Report, error) {
+	report := &Report{}
+
+	var repoPaths []string
+	var errs []error
+	for _, dir := range dirs {
+		o.logger.Printf("organizing dir '%s'", dir)
+
+		paths, err := o.listDir(report, dir)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not list dir '%s': %w", dir, err))
+			continue
+		}
+
+		repoPaths = append(repoPaths, paths...)
+	}
+
+	report.Merge(o.OrganizePaths(ctx, repoPaths))
+
+	return report, errors.Join(errs...)
+}
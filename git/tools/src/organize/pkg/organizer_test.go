@@ -0,0 +1,236 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizer(t *testing.T) {
+	t.Run("PlanAndExecute", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		o := NewOrganizer(WithConfig(cfg))
+
+		plan, err := o.Plan(context.Background(), repoDir, repo)
+		require.NoError(t, err)
+		assert.Equal(t, path.Join(cfg.Destination, "originuser", "origin"), plan.Source)
+		assert.NoDirExists(t, plan.Source)
+
+		require.NoError(t, o.Execute(context.Background(), plan))
+		assert.FileExists(t, path.Join(plan.Source, "README.md"))
+		assert.NoDirExists(t, path.Join(cfg.StagePath(), RepoBaseName))
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		var calls []string
+		o := NewOrganizer(
+			WithConfig(cfg),
+			WithPreHook(func(_ context.Context, plan RepoPlan) error {
+				calls = append(calls, "pre "+plan.RepoPath)
+				return nil
+			}),
+			WithPostHook(func(_ context.Context, plan RepoPlan, err error) {
+				assert.NoError(t, err)
+				assert.DirExists(t, plan.Source)
+				calls = append(calls, "post "+plan.RepoPath)
+			}),
+		)
+
+		plan, err := o.Plan(context.Background(), repoDir, repo)
+		require.NoError(t, err)
+		require.NoError(t, o.Execute(context.Background(), plan))
+		assert.Equal(t, []string{"pre " + repoDir, "post " + repoDir}, calls)
+	})
+
+	t.Run("PreHookError", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		hookErr := errors.New("not today")
+
+		var postErr error
+		o := NewOrganizer(
+			WithConfig(cfg),
+			WithPreHook(func(context.Context, RepoPlan) error {
+				return hookErr
+			}),
+			WithPostHook(func(_ context.Context, _ RepoPlan, err error) {
+				postErr = err
+			}),
+		)
+
+		plan, err := o.Plan(context.Background(), repoDir, repo)
+		require.NoError(t, err)
+		require.ErrorIs(t, o.Execute(context.Background(), plan), hookErr)
+		assert.ErrorIs(t, postErr, hookErr)
+		assert.NoDirExists(t, plan.Source)
+	})
+
+	t.Run("OrganizeAll", func(t *testing.T) {
+		tempDir := t.TempDir()
+		input := path.Join(tempDir, "input")
+
+		RepoWithRemotes(t, input, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.Ignore = []string{"ignored"}
+
+		require.NoError(t, os.MkdirAll(path.Join(input, "ignored"), 0755))
+
+		report, err := NewOrganizer(WithConfig(cfg)).OrganizeAll(context.Background(), input, path.Join(tempDir, "missing"))
+		assert.Error(t, err)
+		assert.Equal(t, []ReportEntry{
+			{Path: path.Join(input, "ignored"), Status: StatusSkipped, Reason: "matched ignore rules"},
+			{Path: path.Join(input, RepoBaseName), Status: StatusOrganized},
+		}, report.Entries)
+		assert.FileExists(t, path.Join(cfg.Destination, "originuser", "origin", "README.md"))
+	})
+
+	t.Run("InMemory", func(t *testing.T) {
+		fs := memfs.New()
+
+		FSRepoWithRemotes(t, fs, "/input", []*config.RemoteConfig{remoteOrigin, remoteUpstream})
+		require.NoError(t, util.WriteFile(fs, "/input/notes/todo.txt", []byte("organize things"), 0644))
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = "/destination"
+		cfg.RemoteStrategy = StrategySymlink
+		cfg.Unsorted = "unsorted"
+
+		report, err := NewOrganizer(WithConfig(cfg), WithFilesystem(fs)).OrganizeAll(context.Background(), "/input")
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Count(StatusOrganized))
+		assert.Equal(t, 1, report.Count(StatusMoved))
+
+		data, err := util.ReadFile(fs, "/destination/originuser/origin/README.md")
+		require.NoError(t, err)
+		assert.Equal(t, "sapmle repo for testing", string(data))
+
+		target, err := fs.Readlink("/destination/upstreamuser/upstream")
+		require.NoError(t, err)
+		assert.Equal(t, "/destination/originuser/origin", target)
+
+		_, err = fs.Stat(path.Join(cfg.UnsortedPath(), "notes", "todo.txt"))
+		assert.NoError(t, err)
+
+		repo, err := openRepo(fs, "/destination/originuser/origin")
+		require.NoError(t, err)
+
+		remote, err := repo.Remote("origin")
+		require.NoError(t, err)
+		assert.Equal(t, remoteOrigin.URLs, remote.Config().URLs)
+	})
+
+	t.Run("InMemoryRelativePaths", func(t *testing.T) {
+		fs := memfs.New()
+
+		repoDir, repo := FSRepoWithRemotes(t, fs, "input", []*config.RemoteConfig{remoteOrigin, remoteUpstream})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = "destination"
+		cfg.RemoteStrategy = StrategySymlink
+
+		for _, relative := range []bool{false, true} {
+			cfg.RelativeLinks = relative
+			o := NewOrganizer(WithConfig(cfg), WithFilesystem(fs))
+
+			plan, err := o.Plan(context.Background(), repoDir, repo)
+			require.NoError(t, err)
+			assert.Equal(t, "destination/originuser/origin", plan.Source)
+			assert.Equal(t, []string{"destination/upstreamuser/upstream"}, plan.Links)
+
+			require.NoError(t, o.Execute(context.Background(), plan))
+
+			target, err := fs.Readlink("/destination/upstreamuser/upstream")
+			require.NoError(t, err)
+			if relative {
+				assert.Equal(t, "../originuser/origin", target)
+			} else {
+				assert.Equal(t, "/destination/originuser/origin", target)
+			}
+
+			// memfs does not follow symlinks to directories, so the link is resolved by hand
+			if !path.IsAbs(target) {
+				target = path.Join("/destination/upstreamuser", target)
+			}
+
+			data, err := util.ReadFile(fs, path.Join(target, "README.md"))
+			require.NoError(t, err)
+			assert.Equal(t, "sapmle repo for testing", string(data))
+		}
+	})
+
+	t.Run("Rerun", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		o := NewOrganizer(WithConfig(cfg))
+
+		plan, err := o.Plan(context.Background(), repoDir, repo)
+		require.NoError(t, err)
+		require.NoError(t, o.Execute(context.Background(), plan))
+
+		// a copy which does not match the original leaves the organized repo as it was
+		require.NoError(t, os.WriteFile(path.Join(plan.Source, "stale.txt"), []byte("stale"), 0644))
+
+		stagedRepo, _, err := o.stage(context.Background(), repoDir)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path.Join(stagedRepo, "README.md"), []byte("changed"), 0644))
+
+		_, err = o.executeStaged(context.Background(), plan, stagedRepo)
+		require.ErrorIs(t, err, ErrVerificationFailed)
+		assertFileContents(t, "sapmle repo for testing", path.Join(plan.Source, "README.md"))
+		assert.FileExists(t, path.Join(plan.Source, "stale.txt"))
+		assert.NoDirExists(t, siblingPath(plan.Source, "organizing"))
+
+		// a verified copy replaces the organized repo entirely
+		require.NoError(t, util.RemoveAll(o.fs, stagedRepo))
+		require.NoError(t, o.Execute(context.Background(), plan))
+		assertFileContents(t, "sapmle repo for testing", path.Join(plan.Source, "README.md"))
+		assert.NoFileExists(t, path.Join(plan.Source, "stale.txt"))
+		assert.NoDirExists(t, siblingPath(plan.Source, "organizing"))
+		assert.NoDirExists(t, siblingPath(plan.Source, "replaced"))
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, _ := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		report := NewOrganizer(WithConfig(cfg)).OrganizePaths(ctx, []string{repoDir})
+		require.Len(t, report.Entries, 1)
+		assert.Equal(t, StatusSkipped, report.Entries[0].Status)
+		assert.NoDirExists(t, path.Join(cfg.Destination, "originuser", "origin"))
+	})
+}
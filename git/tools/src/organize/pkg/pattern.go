@@ -0,0 +1,110 @@
+package organize
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a remote pattern as a regular expression rather than a glob.
+const regexPrefix = "re:"
+
+// remoteFields are the parts of a remote a pattern can be matched against, given as a prefix of the pattern.
+var remoteFields = []string{"name", "url", "host"}
+
+// remotePattern matches remotes by their name, urls, or hosts. Patterns are written as '[field:]pattern',
+// where field is one of remoteFields and defaults to name. The pattern is a glob as used by path.Match unless
+// it starts with regexPrefix, in which case it is an unanchored regular expression. Note that '*' does not
+// match '/' in globs, which matters for url patterns.
+type remotePattern struct {
+	field string
+	glob  string
+	re    *regexp.Regexp
+}
+
+func parseRemotePattern(s string) (remotePattern, error) {
+	pattern := remotePattern{field: "name"}
+
+	for _, field := range remoteFields {
+		if rest, found := strings.CutPrefix(s, field+":"); found {
+			pattern.field, s = field, rest
+			break
+		}
+	}
+
+	if expr, found := strings.CutPrefix(s, regexPrefix); found {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return pattern, fmt.Errorf("bad regular expression '%s': %w", expr, err)
+		}
+
+		pattern.re = re
+		return pattern, nil
+	}
+
+	if _, err := path.Match(s, ""); err != nil {
+		return pattern, fmt.Errorf("bad pattern '%s': %w", s, err)
+	}
+
+	pattern.glob = s
+
+	return pattern, nil
+}
+
+func (pattern remotePattern) matchValue(value string) bool {
+	if pattern.re != nil {
+		return pattern.re.MatchString(value)
+	}
+
+	matched, _ := path.Match(pattern.glob, value)
+	return matched
+}
+
+// matches returns true if pattern matches the remote's name, or any of its urls or their hosts.
+func (pattern remotePattern) matches(name string, urls []string) bool {
+	var values []string
+
+	switch pattern.field {
+	case "name":
+		values = []string{name}
+	case "url":
+		values = urls
+	case "host":
+		for _, u := range urls {
+			if host, err := urlHost(u); err == nil {
+				values = append(values, host)
+			}
+		}
+	}
+
+	for _, value := range values {
+		if pattern.matchValue(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchRemote returns true if any of patterns matches the remote. Patterns which cannot be parsed never
+// match, Config.Validate reports them.
+func matchRemote(patterns []string, name string, urls []string) bool {
+	for _, s := range patterns {
+		if pattern, err := parseRemotePattern(s); err == nil && pattern.matches(name, urls) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateRemotePatterns(patterns []string) error {
+	for _, s := range patterns {
+		if _, err := parseRemotePattern(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package organize
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// Progress is notified as repos are organized, so that progress can be shown while large repos are copied.
+// Copied may be called from a different goroutine than the other methods.
+type Progress interface {
+	// Start is called before any repos are organized, with how many repos will be organized and how many
+	// bytes will be copied in total. Each repo is copied twice, once into the stage and once into place.
+	Start(repos int, bytes int64)
+
+	// StartRepo is called before a repo is organized, with how many bytes will be copied for it.
+	StartRepo(repoPath string, bytes int64)
+
+	// Copied is called as data is copied, with the number of bytes copied since the last call.
+	Copied(n int64)
+
+	// FinishRepo is called once a repo has been organized, whether or not it succeeded.
+	FinishRepo(repoPath string)
+
+	// Finish is called once every repo has been organized.
+	Finish()
+}
+
+// WithProgress sets where progress is reported to while repos are organized. By default it is not reported.
+func WithProgress(progress Progress) Option {
+	return func(o *Organizer) {
+		o.progress = progress
+	}
+}
+
+// nopProgress is a Progress which ignores everything.
+type nopProgress struct{}
+
+func (nopProgress) Start(int, int64)        {}
+func (nopProgress) StartRepo(string, int64) {}
+func (nopProgress) Copied(int64)            {}
+func (nopProgress) FinishRepo(string)       {}
+func (nopProgress) Finish()                 {}
+
+// ProgressState is a snapshot of the progress of organizing repos.
+type ProgressState struct {
+	Repos       int
+	ReposDone   int
+	Bytes       int64
+	BytesCopied int64
+	Current     string
+	Started     time.Time
+}
+
+// ETA estimates how long is left from the rate bytes have been copied at so far. False is returned if nothing
+// has been copied yet.
+func (s ProgressState) ETA(now time.Time) (time.Duration, bool) {
+	elapsed := now.Sub(s.Started)
+	if s.BytesCopied <= 0 || elapsed <= 0 {
+		return 0, false
+	}
+
+	remaining := s.Bytes - s.BytesCopied
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return time.Duration(float64(elapsed) * float64(remaining) / float64(s.BytesCopied)), true
+}
+
+// ProgressTracker is a Progress which keeps track of the current state so that it can be displayed. It is safe
+// to use from multiple goroutines.
+type ProgressTracker struct {
+	mu    sync.Mutex
+	state ProgressState
+	now   func() time.Time
+
+	// repoStart and repoBytes are used to account for the rest of a repo which was not completely copied
+	repoStart int64
+	repoBytes int64
+}
+
+// NewProgressTracker creates a ProgressTracker.
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{now: time.Now}
+}
+
+// Start resets the progress for a new run.
+func (tracker *ProgressTracker) Start(repos int, bytes int64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.state = ProgressState{Repos: repos, Bytes: bytes, Started: tracker.now()}
+}
+
+// StartRepo records repoPath as the repo currently being organized.
+func (tracker *ProgressTracker) StartRepo(repoPath string, bytes int64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.state.Current = repoPath
+	tracker.repoStart = tracker.state.BytesCopied
+	tracker.repoBytes = bytes
+}
+
+// Copied adds n to the bytes copied so far.
+func (tracker *ProgressTracker) Copied(n int64) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	tracker.state.BytesCopied += n
+}
+
+// FinishRepo also counts any bytes which were expected for the repo but were not copied, such as when it
+// failed part way through, so that they are not included in the ETA.
+func (tracker *ProgressTracker) FinishRepo(string) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if expected := tracker.repoStart + tracker.repoBytes; tracker.state.BytesCopied < expected {
+		tracker.state.BytesCopied = expected
+	}
+
+	tracker.state.ReposDone++
+	tracker.state.Current = ""
+	tracker.repoStart, tracker.repoBytes = tracker.state.BytesCopied, 0
+}
+
+// Finish does nothing, the final state is kept until the next run is started.
+func (tracker *ProgressTracker) Finish() {}
+
+// State returns the current progress.
+func (tracker *ProgressTracker) State() ProgressState {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	return tracker.state
+}
+
+// countingReader reports how many bytes are read from it.
+type countingReader struct {
+	r      io.Reader
+	copied func(int64)
+}
+
+func (r countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.copied(int64(n))
+	}
+
+	return n, err
+}
+
+// dirSize returns the total size of the regular files in dir.
+func dirSize(fs billy.Filesystem, dir string) (int64, error) {
+	var size int64
+
+	err := util.Walk(fs, dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+
+		return nil
+	})
+
+	return size, err
+}
@@ -0,0 +1,71 @@
+package organize
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgress(t *testing.T) {
+	t.Run("OrganizePaths", func(t *testing.T) {
+		tempDir := t.TempDir()
+		first, _ := RepoWithRemotes(t, path.Join(tempDir, "first"), []*config.RemoteConfig{remoteOrigin})
+		second, _ := RepoWithRemotes(t, path.Join(tempDir, "second"), []*config.RemoteConfig{{Name: "origin", URLs: remoteMirror.URLs}})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		firstSize, err := dirSize(NewOSFilesystem(), first)
+		require.NoError(t, err)
+		secondSize, err := dirSize(NewOSFilesystem(), second)
+		require.NoError(t, err)
+
+		tracker := NewProgressTracker()
+		report := NewOrganizer(WithConfig(cfg), WithProgress(tracker)).OrganizePaths(context.Background(), []string{first, second})
+		require.Equal(t, 2, report.Count(StatusOrganized))
+
+		state := tracker.State()
+		assert.Equal(t, 2, state.Repos)
+		assert.Equal(t, 2, state.ReposDone)
+		assert.Equal(t, 2*(firstSize+secondSize), state.Bytes)
+		assert.Equal(t, state.Bytes, state.BytesCopied)
+		assert.Empty(t, state.Current)
+	})
+
+	t.Run("FailedRepo", func(t *testing.T) {
+		tracker := NewProgressTracker()
+		tracker.Start(2, 300)
+
+		tracker.StartRepo("first", 200)
+		tracker.Copied(50)
+		assert.Equal(t, "first", tracker.State().Current)
+
+		// the rest of a repo which failed is not left to be copied
+		tracker.FinishRepo("first")
+		assert.Equal(t, int64(200), tracker.State().BytesCopied)
+
+		tracker.StartRepo("second", 100)
+		tracker.Copied(100)
+		tracker.FinishRepo("second")
+		assert.Equal(t, int64(300), tracker.State().BytesCopied)
+		assert.Equal(t, 2, tracker.State().ReposDone)
+	})
+
+	t.Run("ETA", func(t *testing.T) {
+		start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+		state := ProgressState{Bytes: 400, Started: start}
+		_, ok := state.ETA(start.Add(time.Minute))
+		assert.False(t, ok)
+
+		state.BytesCopied = 100
+		eta, ok := state.ETA(start.Add(time.Minute))
+		assert.True(t, ok)
+		assert.Equal(t, 3*time.Minute, eta)
+	})
+}
@@ -0,0 +1,139 @@
+package organize
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// QuarantinedRepo is a repo in the quarantine directory.
+type QuarantinedRepo struct {
+	Path    string
+	Remotes []ManifestRemote
+
+	// Metadata describes why the repo was quarantined. Repos which were quarantined before the reason was
+	// recorded have empty metadata.
+	Metadata BucketMetadata
+
+	// Err is the error encountered reading the repo, if any.
+	Err error
+}
+
+// ListQuarantined returns every repo in the quarantine directory.
+func (o *Organizer) ListQuarantined(_ context.Context) ([]QuarantinedRepo, error) {
+	quarantine := o.config.QuarantinePath()
+
+	items, err := o.fs.ReadDir(quarantine)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var repos []QuarantinedRepo
+	for _, item := range items {
+		if !item.IsDir() {
+			continue
+		}
+
+		repoPath := path.Join(quarantine, item.Name())
+		repo := QuarantinedRepo{Path: repoPath}
+
+		entry, err := o.manifestRepo(repoPath)
+		if err != nil {
+			repo.Err = fmt.Errorf("could not read repo: %w", err)
+		}
+		repo.Remotes = entry.Remotes
+
+		metadata, err := ReadBucketMetadata(o.fs, repoPath)
+		if err != nil && !os.IsNotExist(err) && repo.Err == nil {
+			repo.Err = err
+		}
+		repo.Metadata = metadata
+
+		repos = append(repos, repo)
+	}
+
+	return repos, nil
+}
+
+// WriteQuarantined writes repos to w as a table.
+func WriteQuarantined(w io.Writer, repos []QuarantinedRepo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "PATH\tREMOTES\tREASON")
+
+	for _, repo := range repos {
+		remotes := make([]string, 0, len(repo.Remotes))
+		for _, remote := range repo.Remotes {
+			remotes = append(remotes, fmt.Sprintf("%s=%s", remote.Name, strings.Join(remote.URLs, ",")))
+		}
+
+		reason := repo.Metadata.Reason
+		if repo.Err != nil {
+			reason = repo.Err.Error()
+		} else if reason == "" {
+			reason = "unknown"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", repo.Path, strings.Join(remotes, " "), reason)
+	}
+
+	return tw.Flush()
+}
+
+// ResolveQuarantined organizes the quarantined repo at repoPath and removes it from the quarantine. If
+// primary is not empty the repo is organized by that remote, and if strategy is not empty it is used for the
+// repo's other remotes. When only primary is given, the other remotes are ignored.
+func (o *Organizer) ResolveQuarantined(ctx context.Context, repoPath string, primary string, strategy MultipleRemoteStrategy) (RepoPlan, error) {
+	if absPath(o.fs, path.Dir(repoPath)) != absPath(o.fs, o.config.QuarantinePath()) {
+		return RepoPlan{}, fmt.Errorf("'%s' is not in quarantine", repoPath)
+	}
+
+	resolver := *o
+
+	if primary != "" {
+		resolver.config.PrimaryRemote = primary
+		if strategy == StrategyDefault {
+			strategy = StrategyOrigin
+		}
+	}
+
+	if strategy != StrategyDefault {
+		resolver.config.RemoteStrategy = strategy
+	}
+
+	repo, err := openRepo(o.fs, repoPath)
+	if err != nil {
+		return RepoPlan{}, fmt.Errorf("could not open repo '%s': %w", repoPath, err)
+	}
+
+	plan, err := resolver.Plan(ctx, repoPath, repo)
+	if err != nil {
+		return plan, err
+	}
+
+	if plan.QuarantineReason != "" {
+		return plan, fmt.Errorf("repo '%s' would be quarantined again, a remote or strategy is required", repoPath)
+	}
+
+	if err := resolver.Execute(ctx, plan); err != nil {
+		return plan, err
+	}
+
+	if err := util.RemoveAll(o.fs, repoPath); err != nil {
+		return plan, fmt.Errorf("could not remove '%s' from quarantine: %w", repoPath, err)
+	}
+
+	if err := o.fs.Remove(repoPath + BucketMetadataSuffix); err != nil && !os.IsNotExist(err) {
+		return plan, fmt.Errorf("could not remove metadata for '%s': %w", repoPath, err)
+	}
+
+	return plan, nil
+}
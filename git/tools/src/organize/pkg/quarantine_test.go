@@ -0,0 +1,91 @@
+package organize
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuarantine(t *testing.T) {
+	setup := func(t *testing.T) (*Organizer, string) {
+		tempDir := t.TempDir()
+		repoDir, _ := RepoWithRemotes(t, path.Join(tempDir, "input"), []*config.RemoteConfig{remoteOrigin, remoteMirror})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		o := NewOrganizer(WithConfig(cfg))
+
+		report := o.OrganizePaths(context.Background(), []string{repoDir})
+		require.Equal(t, 1, report.Count(StatusOrganized))
+
+		return o, path.Join(cfg.QuarantinePath(), RepoBaseName)
+	}
+
+	t.Run("List", func(t *testing.T) {
+		o, quarantined := setup(t)
+
+		repos, err := o.ListQuarantined(context.Background())
+		require.NoError(t, err)
+		require.Len(t, repos, 1)
+
+		assert.NoError(t, repos[0].Err)
+		assert.Equal(t, quarantined, repos[0].Path)
+		assert.Equal(t, []ManifestRemote{
+			{Name: "mirror", URLs: remoteMirror.URLs},
+			{Name: "origin", URLs: remoteOrigin.URLs},
+		}, repos[0].Remotes)
+		assert.Equal(t, "repo has multiple remotes: mirror, origin", repos[0].Metadata.Reason)
+	})
+
+	t.Run("ResolveRemote", func(t *testing.T) {
+		o, quarantined := setup(t)
+
+		plan, err := o.ResolveQuarantined(context.Background(), quarantined, "mirror", StrategyDefault)
+		require.NoError(t, err)
+		assert.Equal(t, path.Join(o.Config().Destination, "mirroruser", "mirror"), plan.Source)
+		assert.Empty(t, plan.Links)
+		assert.DirExists(t, plan.Source)
+		assert.NoDirExists(t, quarantined)
+		assert.NoFileExists(t, quarantined+BucketMetadataSuffix)
+
+		repos, err := o.ListQuarantined(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, repos)
+	})
+
+	t.Run("ResolveStrategy", func(t *testing.T) {
+		o, quarantined := setup(t)
+
+		plan, err := o.ResolveQuarantined(context.Background(), quarantined, "", StrategySymlink)
+		require.NoError(t, err)
+		assert.Equal(t, path.Join(o.Config().Destination, "originuser", "origin"), plan.Source)
+		assert.Equal(t, []string{path.Join(o.Config().Destination, "mirroruser", "mirror")}, plan.Links)
+		assert.DirExists(t, plan.Source)
+		assert.NoDirExists(t, quarantined)
+	})
+
+	t.Run("StillQuarantined", func(t *testing.T) {
+		o, quarantined := setup(t)
+
+		_, err := o.ResolveQuarantined(context.Background(), quarantined, "", StrategyDefault)
+		require.Error(t, err)
+		assert.DirExists(t, quarantined)
+	})
+
+	t.Run("NotQuarantined", func(t *testing.T) {
+		o, _ := setup(t)
+
+		repoDir := path.Join(o.Config().Destination, "elsewhere")
+		require.NoError(t, os.MkdirAll(repoDir, 0755))
+
+		_, err := o.ResolveQuarantined(context.Background(), repoDir, "origin", StrategyDefault)
+		require.Error(t, err)
+		assert.DirExists(t, repoDir)
+	})
+}
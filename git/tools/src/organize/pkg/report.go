@@ -0,0 +1,86 @@
+package organize
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Status describes what happened to a directory during an organize run.
+type Status string
+
+const (
+	// StatusOrganized means the directory was organized into the destination.
+	StatusOrganized Status = "organized"
+
+	// StatusSkipped means the directory was intentionally left alone.
+	StatusSkipped Status = "skipped"
+
+	// StatusMoved means the directory could not be organized, and was moved into a bucket instead.
+	StatusMoved Status = "moved"
+
+	// StatusFailed means an error was encountered while organizing the directory.
+	StatusFailed Status = "failed"
+)
+
+// ReportEntry is the outcome of organizing a single directory.
+type ReportEntry struct {
+	Path   string
+	Status Status
+	Reason string
+
+	// Changes describes each change made to the repo's config while organizing it.
+	Changes []string
+}
+
+// Report collects the outcome of every directory considered during an organize run.
+type Report struct {
+	Entries []ReportEntry
+}
+
+func (report *Report) Add(path string, status Status, reason string) {
+	report.AddChanges(path, status, reason, nil)
+}
+
+// AddChanges adds an entry along with the changes made to the repo's config.
+func (report *Report) AddChanges(path string, status Status, reason string, changes []string) {
+	report.Entries = append(report.Entries, ReportEntry{
+		Path:    path,
+		Status:  status,
+		Reason:  reason,
+		Changes: changes,
+	})
+}
+
+// Merge adds all entries in other to report.
+func (report *Report) Merge(other *Report) {
+	report.Entries = append(report.Entries, other.Entries...)
+}
+
+// Count returns the amount of entries with the given status.
+func (report *Report) Count(status Status) int {
+	count := 0
+	for _, entry := range report.Entries {
+		if entry.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Write writes the report as a human readable table to w.
+func (report *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "PATH\tSTATUS\tREASON")
+	for _, entry := range report.Entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", entry.Path, entry.Status, entry.Reason)
+		for _, change := range entry.Changes {
+			fmt.Fprintf(tw, "\t\t%s\n", change)
+		}
+	}
+
+	fmt.Fprintf(tw, "\n%d organized, %d moved, %d skipped, %d failed\n", report.Count(StatusOrganized), report.Count(StatusMoved), report.Count(StatusSkipped), report.Count(StatusFailed))
+
+	return tw.Flush()
+}
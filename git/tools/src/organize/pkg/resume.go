@@ -0,0 +1,101 @@
+package organize
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
+)
+
+// ResumeFileName is the name of the file in Config.Destination listing the repos an interrupted run did not
+// organize.
+const ResumeFileName = ".organize.resume"
+
+// ResumeState lists the repos an interrupted run did not organize.
+type ResumeState struct {
+	Time  time.Time `yaml:"time"`
+	Paths []string  `yaml:"paths"`
+}
+
+func (o *Organizer) resumePath() string {
+	return path.Join(o.config.DestinationPath(), ResumeFileName)
+}
+
+// ReadResumeState reads the repos left by interrupted runs.
+func (o *Organizer) ReadResumeState() (ResumeState, error) {
+	var state ResumeState
+
+	data, err := util.ReadFile(o.fs, o.resumePath())
+	if err != nil {
+		return state, err
+	}
+
+	if err := yaml.Unmarshal(data, &state); err != nil {
+		return state, fmt.Errorf("could not parse resume file '%s': %w", o.resumePath(), err)
+	}
+
+	return state, nil
+}
+
+// writeResumeState adds repoPaths to the resume file, keeping any repos left by earlier interrupted runs.
+func (o *Organizer) writeResumeState(repoPaths []string) error {
+	state, err := o.ReadResumeState()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	state.Time = time.Now()
+	for _, repoPath := range repoPaths {
+		if repoPath = absPath(o.fs, repoPath); !slices.Contains(state.Paths, repoPath) {
+			state.Paths = append(state.Paths, repoPath)
+		}
+	}
+
+	data, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	if err := o.fs.MkdirAll(o.config.DestinationPath(), 0755); err != nil {
+		return err
+	}
+
+	return util.WriteFile(o.fs, o.resumePath(), data, 0644)
+}
+
+// Resume organizes the repos left by interrupted runs.
+func (o *Organizer) Resume(ctx context.Context) (*Report, error) {
+	state, err := o.ReadResumeState()
+	if os.IsNotExist(err) {
+		return &Report{}, fmt.Errorf("there is no interrupted run to resume")
+	} else if err != nil {
+		return &Report{}, err
+	}
+
+	report := &Report{}
+
+	repoPaths := make([]string, 0, len(state.Paths))
+	for _, repoPath := range state.Paths {
+		if o.exists(repoPath) {
+			repoPaths = append(repoPaths, repoPath)
+		} else {
+			report.Add(repoPath, StatusSkipped, "no longer exists")
+		}
+	}
+
+	// any repos which are still not organized are written back if this run is interrupted too
+	if err := o.fs.Remove(o.resumePath()); err != nil {
+		return report, fmt.Errorf("could not remove resume file: %w", err)
+	}
+
+	o.logger.Printf("resuming run interrupted at %s", state.Time.Format(time.RFC3339))
+
+	report.Merge(o.OrganizePaths(ctx, repoPaths))
+
+	return report, nil
+}
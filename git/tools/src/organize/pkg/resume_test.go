@@ -0,0 +1,114 @@
+package organize
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cancelProgress cancels the organizer's context as soon as anything is copied.
+type cancelProgress struct {
+	nopProgress
+	cancel context.CancelFunc
+}
+
+func (progress cancelProgress) Copied(int64) {
+	progress.cancel()
+}
+
+func TestResume(t *testing.T) {
+	t.Run("RollBack", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		o := NewOrganizer(WithConfig(cfg))
+
+		plan, err := o.Plan(context.Background(), repoDir, repo)
+		require.NoError(t, err)
+
+		stagedRepo, _, err := o.stage(context.Background(), repoDir)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err = o.executeStaged(ctx, plan, stagedRepo)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.NoDirExists(t, plan.Source)
+		assert.DirExists(t, stagedRepo)
+	})
+
+	t.Run("RollBackRerun", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		o := NewOrganizer(WithConfig(cfg))
+
+		plan, err := o.Plan(context.Background(), repoDir, repo)
+		require.NoError(t, err)
+		require.NoError(t, o.Execute(context.Background(), plan))
+		require.NoError(t, os.WriteFile(path.Join(plan.Source, "README.md"), []byte("organized"), 0644))
+
+		stagedRepo, _, err := o.stage(context.Background(), repoDir)
+		require.NoError(t, err)
+
+		// the signal arrives part way through copying the repo into place
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		o.progress = cancelProgress{cancel: cancel}
+
+		_, err = o.executeStaged(ctx, plan, stagedRepo)
+		require.ErrorIs(t, err, context.Canceled)
+		assertFileContents(t, "organized", path.Join(plan.Source, "README.md"))
+		assert.NoDirExists(t, siblingPath(plan.Source, "organizing"))
+		assert.DirExists(t, stagedRepo)
+	})
+
+	t.Run("Interrupted", func(t *testing.T) {
+		tempDir := t.TempDir()
+		first, _ := RepoWithRemotes(t, path.Join(tempDir, "first"), []*config.RemoteConfig{remoteOrigin})
+		second, _ := RepoWithRemotes(t, path.Join(tempDir, "second"), []*config.RemoteConfig{{Name: "origin", URLs: remoteMirror.URLs}})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		// the signal arrives once the first repo has started being organized
+		o := NewOrganizer(WithConfig(cfg), WithPreHook(func(context.Context, RepoPlan) error {
+			cancel()
+			return nil
+		}))
+
+		report := o.OrganizePaths(ctx, []string{first, second})
+		assert.Equal(t, 2, report.Count(StatusSkipped))
+		assert.NoDirExists(t, path.Join(cfg.Destination, "originuser", "origin"))
+		assert.NoDirExists(t, path.Join(cfg.StagePath(), RepoBaseName))
+
+		state, err := o.ReadResumeState()
+		require.NoError(t, err)
+		assert.Equal(t, []string{first, second}, state.Paths)
+
+		report, err = NewOrganizer(WithConfig(cfg)).Resume(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Count(StatusOrganized))
+		assert.DirExists(t, path.Join(cfg.Destination, "originuser", "origin"))
+		assert.DirExists(t, path.Join(cfg.Destination, "mirroruser", "mirror"))
+		assert.NoFileExists(t, path.Join(cfg.Destination, ResumeFileName))
+
+		_, err = NewOrganizer(WithConfig(cfg)).Resume(context.Background())
+		assert.Error(t, err)
+	})
+}
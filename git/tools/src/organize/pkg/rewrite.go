@@ -0,0 +1,417 @@
+package organize
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	format "github.com/go-git/go-git/v5/plumbing/format/config"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// ProtocolSSH converts remote urls to scp-like ssh urls such as 'git@github.com:owner/name.git'.
+	ProtocolSSH = "ssh"
+
+	// ProtocolHTTPS converts remote urls to https urls such as 'https://github.com/owner/name.git'.
+	ProtocolHTTPS = "https"
+)
+
+// RemoteRewrites controls how the remotes of repos are changed once they are organized. Credentials are
+// stripped first, then URLRewrites are applied, and then urls are converted to Protocol.
+type RemoteRewrites struct {
+	// Protocol converts ssh urls to https or https urls to ssh. If Protocol is empty urls keep their
+	// protocol. Urls using any other protocol are never converted.
+	Protocol string `yaml:"protocol"`
+
+	// URLRewrites maps url prefixes to the prefix they are replaced with, the same as git's
+	// url.<base>.insteadOf where the key is the insteadOf value and the value is the base. Only the longest
+	// matching prefix is replaced.
+	URLRewrites map[string]string `yaml:"url-rewrites"`
+
+	// StripCredentials removes any user and password embedded in http and https urls, and any password
+	// embedded in ssh urls.
+	StripCredentials bool `yaml:"strip-credentials"`
+
+	// Renames maps the names of remotes to the names they are renamed to. Repos are organized by their
+	// remotes as renamed, so renaming Config.PrimaryRemote also needs it set to the new name.
+	Renames map[string]string `yaml:"renames"`
+
+	// ForkOwners are the owners of forks. A remote owned by one of them is renamed to "origin", and if there
+	// is exactly one other remote for a repo with the same name, it is renamed to "upstream". Renames takes
+	// precedence over ForkOwners.
+	ForkOwners []string `yaml:"fork-owners"`
+}
+
+func (rewrites RemoteRewrites) validate() error {
+	if !slices.Contains([]string{"", ProtocolSSH, ProtocolHTTPS}, rewrites.Protocol) {
+		return fmt.Errorf("unsupported remote protocol '%s'", rewrites.Protocol)
+	}
+
+	return nil
+}
+
+// empty returns true if rewrites would never change a remote.
+func (rewrites RemoteRewrites) empty() bool {
+	return rewrites.Protocol == "" && len(rewrites.URLRewrites) == 0 && !rewrites.StripCredentials &&
+		len(rewrites.Renames) == 0 && len(rewrites.ForkOwners) == 0
+}
+
+// isSCPLike returns true if u is an scp-like url such as 'git@github.com:owner/name.git' or
+// 'github.com:owner/name.git'.
+func isSCPLike(u string) bool {
+	if strings.Contains(u, "://") {
+		return false
+	}
+
+	colon := strings.Index(u, ":")
+	slash := strings.Index(u, "/")
+
+	return colon > 0 && (slash == -1 || colon < slash)
+}
+
+// redactURL returns u with any password replaced, so it can be reported without leaking credentials.
+func redactURL(u string) string {
+	if parsed, err := url.Parse(u); err == nil && parsed.User != nil && !isSCPLike(u) {
+		return parsed.Redacted()
+	}
+
+	return u
+}
+
+func stripCredentials(u string) string {
+	if isSCPLike(u) {
+		return u
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.User == nil {
+		return u
+	}
+
+	switch parsed.Scheme {
+	case "http", "https":
+		parsed.User = nil
+	default:
+		if _, hasPassword := parsed.User.Password(); !hasPassword {
+			return u
+		}
+		parsed.User = url.User(parsed.User.Username())
+	}
+
+	return parsed.String()
+}
+
+// rewriteURL applies the longest matching prefix in rewrites to u.
+func rewriteURL(rewrites map[string]string, u string) string {
+	var longest string
+	for prefix := range rewrites {
+		if strings.HasPrefix(u, prefix) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+
+	if longest == "" {
+		return u
+	}
+
+	return rewrites[longest] + strings.TrimPrefix(u, longest)
+}
+
+func convertProtocol(protocol string, u string) string {
+	if isSCPLike(u) {
+		if protocol != ProtocolHTTPS {
+			return u
+		}
+
+		host, p, _ := strings.Cut(u, ":")
+		return "https://" + host[strings.LastIndex(host, "@")+1:] + "/" + strings.TrimPrefix(p, "/")
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return u
+	}
+
+	switch {
+	case protocol == ProtocolSSH && parsed.Scheme == "https":
+		return "git@" + parsed.Hostname() + ":" + strings.TrimPrefix(parsed.Path, "/")
+	case protocol == ProtocolHTTPS && parsed.Scheme == "ssh":
+		return "https://" + parsed.Hostname() + "/" + strings.TrimPrefix(parsed.Path, "/")
+	default:
+		return u
+	}
+}
+
+// RewriteURL returns u after applying rewrites.
+func (rewrites RemoteRewrites) RewriteURL(u string) string {
+	if rewrites.StripCredentials {
+		u = stripCredentials(u)
+	}
+
+	u = rewriteURL(rewrites.URLRewrites, u)
+
+	if rewrites.Protocol != "" {
+		u = convertProtocol(rewrites.Protocol, u)
+	}
+
+	return u
+}
+
+// renames returns the new name of each remote which will be renamed, given the urls of every remote.
+func (rewrites RemoteRewrites) renames(remotes map[string][]string) map[string]string {
+	renames := make(map[string]string)
+	for name := range remotes {
+		if to, found := rewrites.Renames[name]; found && to != name {
+			renames[name] = to
+		}
+	}
+
+	if len(rewrites.ForkOwners) == 0 {
+		return renames
+	}
+
+	type ownerAndName struct {
+		owner string
+		name  string
+	}
+
+	parsed := make(map[string]ownerAndName)
+	for name, urls := range remotes {
+		owner, repoName, err := getRemoteOwnerAndName(git.NewRemote(nil, &config.RemoteConfig{Name: name, URLs: urls}))
+		if err == nil {
+			parsed[name] = ownerAndName{owner: owner, name: repoName}
+		}
+	}
+
+	var forks []string
+	for name, remote := range parsed {
+		if slices.Contains(rewrites.ForkOwners, remote.owner) {
+			forks = append(forks, name)
+		}
+	}
+
+	if len(forks) != 1 {
+		return renames
+	}
+
+	fork := forks[0]
+	if _, found := renames[fork]; !found && fork != "origin" {
+		renames[fork] = "origin"
+	}
+
+	var upstreams []string
+	for name, remote := range parsed {
+		if name != fork && remote.name == parsed[fork].name {
+			upstreams = append(upstreams, name)
+		}
+	}
+
+	if len(upstreams) == 1 {
+		if _, found := renames[upstreams[0]]; !found && upstreams[0] != "upstream" {
+			renames[upstreams[0]] = "upstream"
+		}
+	}
+
+	return renames
+}
+
+// conflictingRenames returns a description of each rename which would give two remotes the same name.
+func conflictingRenames(remotes map[string][]string, renames map[string]string) []string {
+	owners := make(map[string][]string)
+	for name := range remotes {
+		to := name
+		if renamed, found := renames[name]; found {
+			to = renamed
+		}
+
+		owners[to] = append(owners[to], name)
+	}
+
+	var conflicts []string
+	for to, names := range owners {
+		if len(names) > 1 {
+			slices.Sort(names)
+			conflicts = append(conflicts, fmt.Sprintf("remotes '%s' would all be named '%s'", strings.Join(names, "', '"), to))
+		}
+	}
+
+	slices.Sort(conflicts)
+
+	return conflicts
+}
+
+// renameRemoteRefs moves the remote tracking refs of each renamed remote.
+func renameRemoteRefs(repo *git.Repository, renames map[string]string) error {
+	renameRef := func(name plumbing.ReferenceName) (plumbing.ReferenceName, bool) {
+		for from, to := range renames {
+			if rest, found := strings.CutPrefix(name.String(), "refs/remotes/"+from+"/"); found {
+				return plumbing.ReferenceName("refs/remotes/" + to + "/" + rest), true
+			}
+		}
+		return name, false
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	var old []*plumbing.Reference
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if _, renamed := renameRef(ref.Name()); renamed {
+			old = append(old, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// every old ref is removed before any new ones are created, in case remotes are swapping names
+	for _, ref := range old {
+		if err := repo.Storer.RemoveReference(ref.Name()); err != nil {
+			return err
+		}
+	}
+
+	for _, ref := range old {
+		name, _ := renameRef(ref.Name())
+
+		renamed := plumbing.NewHashReference(name, ref.Hash())
+		if ref.Type() == plumbing.SymbolicReference {
+			target, _ := renameRef(ref.Target())
+			renamed = plumbing.NewSymbolicReference(name, target)
+		}
+
+		if err := repo.Storer.SetReference(renamed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// renameOption replaces the values of key in options which rename maps to a new value.
+func renameOption(options *format.Options, key string, rename func(value string) string) {
+	for _, option := range *options {
+		if option.IsKey(key) {
+			option.Value = rename(option.Value)
+		}
+	}
+}
+
+// rewriteRemotes applies Config.RewriteRemotes to the remotes of the repo at repoPath, and returns a
+// description of each change made to its config, along with warnings for any renames which were skipped. The config file is edited directly rather than through
+// go-git's config, which would undo url changes to any remotes affected by the repo's own insteadOf rules and
+// drop options it does not know about.
+func (o *Organizer) rewriteRemotes(repoPath string) (executed, error) {
+	rewrites := o.config.RewriteRemotes
+	if rewrites.empty() {
+		return executed{}, nil
+	}
+
+	repo, err := openRepo(o.fs, repoPath)
+	if err != nil {
+		return executed{}, err
+	}
+
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return executed{}, fmt.Errorf("unsupported storage for repo '%s'", repoPath)
+	}
+
+	data, err := util.ReadFile(storage.Filesystem(), "config")
+	if err != nil {
+		return executed{}, err
+	}
+
+	raw := format.New()
+	if err := format.NewDecoder(bytes.NewReader(data)).Decode(raw); err != nil {
+		return executed{}, fmt.Errorf("could not parse config: %w", err)
+	}
+
+	aliases, err := o.aliases.forRepo(repo)
+	if err != nil {
+		return executed{}, err
+	}
+
+	var result executed
+
+	remoteSection := raw.Section("remote")
+	remotes := make(map[string][]string, len(remoteSection.Subsections))
+
+	for _, remote := range remoteSection.Subsections {
+		for _, key := range []string{"url", "pushurl"} {
+			renameOption(&remote.Options, key, func(u string) string {
+				rewritten := rewrites.RewriteURL(u)
+				if rewritten != u {
+					result.changes = append(result.changes, fmt.Sprintf("changed %s of remote '%s' from '%s' to '%s'", key, remote.Name, redactURL(u), redactURL(rewritten)))
+				}
+				return rewritten
+			})
+		}
+
+		remotes[remote.Name] = aliases.resolveURLs(remote.Options.GetAll("url"))
+	}
+
+	renames := rewrites.renames(remotes)
+	if conflicts := conflictingRenames(remotes, renames); len(conflicts) != 0 {
+		result.warnings = append(result.warnings, fmt.Sprintf("not renaming remotes: %s", strings.Join(conflicts, "; ")))
+		renames = nil
+	}
+
+	renameRemote := func(name string) string {
+		if to, found := renames[name]; found {
+			return to
+		}
+		return name
+	}
+
+	for _, remote := range remoteSection.Subsections {
+		from, to := remote.Name, renameRemote(remote.Name)
+		if from == to {
+			continue
+		}
+
+		remote.Name = to
+		renameOption(&remote.Options, "fetch", func(refspec string) string {
+			return strings.ReplaceAll(refspec, "refs/remotes/"+from+"/", "refs/remotes/"+to+"/")
+		})
+
+		result.changes = append(result.changes, fmt.Sprintf("renamed remote '%s' to '%s'", from, to))
+	}
+
+	if len(renames) != 0 {
+		renameOption(&remoteSection.Options, "pushDefault", renameRemote)
+		for _, branch := range raw.Section("branch").Subsections {
+			renameOption(&branch.Options, "remote", renameRemote)
+			renameOption(&branch.Options, "pushRemote", renameRemote)
+		}
+	}
+
+	if len(result.changes) == 0 {
+		return result, nil
+	}
+
+	var buf bytes.Buffer
+	if err := format.NewEncoder(&buf).Encode(raw); err != nil {
+		return executed{}, err
+	}
+
+	if err := util.WriteFile(storage.Filesystem(), "config", buf.Bytes(), 0644); err != nil {
+		return executed{}, fmt.Errorf("could not write config: %w", err)
+	}
+
+	if err := renameRemoteRefs(repo, renames); err != nil {
+		return result, fmt.Errorf("could not rename remote tracking refs: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,117 @@
+package organize
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/exp/slices"
+)
+
+// RuleMatch selects repos by their origin remote. Each non-empty field is a glob pattern, as used by
+// path.Match, which must match for the rule to apply. Note that '*' does not match '/', which matters for URL
+// patterns. Owners and names are matched before normalization.
+type RuleMatch struct {
+	Host  string `yaml:"host"`
+	Owner string `yaml:"owner"`
+	Name  string `yaml:"name"`
+	URL   string `yaml:"url"`
+}
+
+// Rule routes the repos it matches to a different destination or strategy.
+type Rule struct {
+	Match RuleMatch `yaml:"match"`
+
+	// Destination is the top level directory matching repos will be organized into. If Destination is
+	// relative, it will be relative to Config.Destination. If Destination is empty Config.Destination and
+	// Config.HostRoots are used.
+	Destination string `yaml:"destination"`
+
+	// RemoteStrategy is the strategy used for matching repos. If RemoteStrategy is empty,
+	// Config.RemoteStrategy is used.
+	RemoteStrategy MultipleRemoteStrategy `yaml:"remote-strategy"`
+
+	// Hooks are run for matching repos after those in Config.Hooks.
+	Hooks []string `yaml:"hooks"`
+}
+
+func (match RuleMatch) patterns() []string {
+	return []string{match.Host, match.Owner, match.Name, match.URL}
+}
+
+func (match RuleMatch) validate() error {
+	for _, pattern := range match.patterns() {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad pattern '%s': %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// matches returns true if remote matches every pattern in match.
+func (match RuleMatch) matches(remote *git.Remote) bool {
+	var host, owner, name, url string
+
+	if len(remote.Config().URLs) > 0 {
+		url = remote.Config().URLs[0]
+	}
+
+	host, _ = getRemoteHost(remote)
+	owner, name, _ = getRemoteOwnerAndName(remote)
+
+	values := []string{host, owner, name, url}
+	for i, pattern := range match.patterns() {
+		if pattern == "" {
+			continue
+		}
+
+		if matched, _ := path.Match(pattern, values[i]); !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// findRule returns the first rule matching remote, or nil if no rule matches.
+func (config Config) findRule(remote *git.Remote) *Rule {
+	for i, rule := range config.Rules {
+		if rule.Match.matches(remote) {
+			return &config.Rules[i]
+		}
+	}
+
+	return nil
+}
+
+// forRemote returns the config to use when organizing a repo whose origin is remote, after applying the
+// first matching rule.
+func (config Config) forRemote(remote *git.Remote) Config {
+	rule := config.findRule(remote)
+	if rule == nil {
+		return config
+	}
+
+	if rule.RemoteStrategy != StrategyDefault {
+		config.RemoteStrategy = rule.RemoteStrategy
+	}
+
+	if len(rule.Hooks) > 0 {
+		config.Hooks = append(slices.Clone(config.Hooks), rule.Hooks...)
+	}
+
+	if rule.Destination != "" {
+		// keep the directories which are relative to the original destination in place
+		config.BareDestination = config.BareDestinationPath()
+		config.Stage = config.StagePath()
+		config.Quarantine = config.QuarantinePath()
+		config.Unsorted = config.UnsortedPath()
+		config.Broken = config.BrokenPath()
+
+		config.Destination = config.resolve(rule.Destination)
+		config.HostRoots = nil
+	}
+
+	return config
+}
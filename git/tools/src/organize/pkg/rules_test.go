@@ -0,0 +1,100 @@
+package organize
+
+import (
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleMatch(t *testing.T) {
+	remote := git.NewRemote(nil, &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"git@github.com:rancher/rancher.git"},
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.True(t, RuleMatch{}.matches(remote))
+	})
+
+	t.Run("Host", func(t *testing.T) {
+		assert.True(t, RuleMatch{Host: "github.com"}.matches(remote))
+		assert.False(t, RuleMatch{Host: "gitlab.*"}.matches(remote))
+	})
+
+	t.Run("OwnerAndName", func(t *testing.T) {
+		assert.True(t, RuleMatch{Owner: "ranch*", Name: "rancher"}.matches(remote))
+		assert.False(t, RuleMatch{Owner: "ranch*", Name: "fleet"}.matches(remote))
+	})
+
+	t.Run("URL", func(t *testing.T) {
+		assert.True(t, RuleMatch{URL: "git@github.com:rancher/*"}.matches(remote))
+		assert.False(t, RuleMatch{URL: "https://*"}.matches(remote))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		assert.Error(t, RuleMatch{Owner: "[rancher"}.validate())
+	})
+}
+
+func TestPlanRepoRules(t *testing.T) {
+	tempDir := t.TempDir()
+
+	cfg := NewDefaultConfig()
+	cfg.Destination = path.Join(tempDir, "src")
+	cfg.RemoteStrategy = StrategyOrigin
+	cfg.Rules = []Rule{
+		{
+			Match:          RuleMatch{Owner: "upstreamuser"},
+			Destination:    path.Join(tempDir, "work"),
+			RemoteStrategy: StrategySymlink,
+		},
+		{
+			Match:       RuleMatch{Owner: "*"},
+			Destination: "other",
+		},
+	}
+
+	t.Run("FirstRule", func(t *testing.T) {
+		repoDir, repo := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{{
+			Name: "origin",
+			URLs: []string{"git@github.com:upstreamuser/upstream.git"},
+		}, remoteMirror})
+
+		plan, err := PlanRepo(cfg, repoDir, repo)
+		require.NoError(t, err)
+		assert.Equal(t, path.Join(tempDir, "work", "upstreamuser", "upstream"), plan.Source)
+		assert.Equal(t, []string{path.Join(tempDir, "work", "mirroruser", "mirror")}, plan.Links)
+	})
+
+	t.Run("SecondRule", func(t *testing.T) {
+		repoDir, repo := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin, remoteMirror})
+
+		plan, err := PlanRepo(cfg, repoDir, repo)
+		require.NoError(t, err)
+		assert.Equal(t, path.Join(tempDir, "src", "other", "originuser", "origin"), plan.Source)
+		assert.Empty(t, plan.Links)
+	})
+
+	t.Run("NoRule", func(t *testing.T) {
+		noMatch := cfg
+		noMatch.Rules = cfg.Rules[:1]
+
+		repoDir, repo := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+
+		plan, err := PlanRepo(noMatch, repoDir, repo)
+		require.NoError(t, err)
+		assert.Equal(t, path.Join(tempDir, "src", "originuser", "origin"), plan.Source)
+	})
+
+	t.Run("KeepsStageAndQuarantine", func(t *testing.T) {
+		remote := git.NewRemote(nil, remoteOrigin)
+		ruled := cfg.forRemote(remote)
+
+		assert.Equal(t, cfg.StagePath(), ruled.StagePath())
+		assert.Equal(t, cfg.QuarantinePath(), ruled.QuarantinePath())
+	})
+}
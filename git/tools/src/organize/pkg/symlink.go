@@ -0,0 +1,60 @@
+package organize
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// linkTarget returns what link should point to in order to reach source in fs.
+func linkTarget(fs billy.Filesystem, source string, link string, relative bool) (string, error) {
+	absSource := absPath(fs, source)
+
+	// a relative source would be resolved against the link's directory rather than the working directory
+	if !relative {
+		return absSource, nil
+	}
+
+	return filepath.Rel(absPath(fs, path.Dir(link)), absSource)
+}
+
+// ensureSymlink makes sure link is a symlink pointing to target. An existing symlink pointing to target is
+// kept, a stale symlink is replaced, and anything else at link is treated as an error.
+func ensureSymlink(fs billy.Filesystem, target string, link string) error {
+	info, err := fs.Lstat(link)
+	switch {
+	case os.IsNotExist(err):
+		if err := fs.MkdirAll(path.Dir(link), 0755); err != nil {
+			return fmt.Errorf("could not create parent directories for symlink '%s': %w", link, err)
+		}
+	case err != nil:
+		return err
+	case info.Mode()&os.ModeSymlink == 0:
+		if info.IsDir() {
+			return fmt.Errorf("could not create symlink '%s': path is a directory", link)
+		}
+		return fmt.Errorf("could not create symlink '%s': path already exists", link)
+	default:
+		existing, err := fs.Readlink(link)
+		if err != nil {
+			return err
+		}
+
+		if existing == target {
+			return nil
+		}
+
+		if err := fs.Remove(link); err != nil {
+			return fmt.Errorf("could not remove stale symlink '%s' -> '%s': %w", link, existing, err)
+		}
+	}
+
+	if err := fs.Symlink(target, link); err != nil {
+		return fmt.Errorf("could not create symlink '%s' -> '%s': %w", link, target, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package organize
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkTarget(t *testing.T) {
+	t.Run("Absolute", func(t *testing.T) {
+		target, err := linkTarget(NewOSFilesystem(), "/tmp/owner/name", "/tmp/other/name", false)
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/owner/name", target)
+	})
+
+	t.Run("AbsoluteFromRelativeSource", func(t *testing.T) {
+		wd, err := os.Getwd()
+		require.NoError(t, err)
+
+		target, err := linkTarget(NewOSFilesystem(), "owner/name", "other/name", false)
+		require.NoError(t, err)
+		assert.Equal(t, path.Join(wd, "owner", "name"), target)
+	})
+
+	t.Run("InMemory", func(t *testing.T) {
+		target, err := linkTarget(memfs.New(), "owner/name", "other/name", false)
+		require.NoError(t, err)
+		assert.Equal(t, "/owner/name", target)
+
+		target, err = linkTarget(memfs.New(), "owner/name", "other/name", true)
+		require.NoError(t, err)
+		assert.Equal(t, "../owner/name", target)
+	})
+
+	t.Run("Relative", func(t *testing.T) {
+		target, err := linkTarget(NewOSFilesystem(), "/tmp/owner/name", "/tmp/other/name", true)
+		require.NoError(t, err)
+		assert.Equal(t, "../owner/name", target)
+	})
+}
+
+func TestEnsureSymlink(t *testing.T) {
+	t.Run("Create", func(t *testing.T) {
+		tempDir := t.TempDir()
+		link := path.Join(tempDir, "parent", "link")
+
+		require.NoError(t, ensureSymlink(NewOSFilesystem(), "/some/target", link))
+		symlinkExists(t, link)
+
+		target, err := os.Readlink(link)
+		require.NoError(t, err)
+		assert.Equal(t, "/some/target", target)
+	})
+
+	t.Run("KeepCorrect", func(t *testing.T) {
+		tempDir := t.TempDir()
+		link := path.Join(tempDir, "link")
+		require.NoError(t, os.Symlink("/some/target", link))
+
+		before, err := os.Lstat(link)
+		require.NoError(t, err)
+
+		require.NoError(t, ensureSymlink(NewOSFilesystem(), "/some/target", link))
+
+		after, err := os.Lstat(link)
+		require.NoError(t, err)
+		assert.True(t, os.SameFile(before, after))
+	})
+
+	t.Run("ReplaceStale", func(t *testing.T) {
+		tempDir := t.TempDir()
+		link := path.Join(tempDir, "link")
+		require.NoError(t, os.Symlink("/some/old/target", link))
+
+		require.NoError(t, ensureSymlink(NewOSFilesystem(), "/some/target", link))
+
+		target, err := os.Readlink(link)
+		require.NoError(t, err)
+		assert.Equal(t, "/some/target", target)
+	})
+
+	t.Run("RealDirectory", func(t *testing.T) {
+		tempDir := t.TempDir()
+		link := path.Join(tempDir, "link")
+		require.NoError(t, os.MkdirAll(link, 0755))
+
+		require.Error(t, ensureSymlink(NewOSFilesystem(), "/some/target", link))
+		assert.DirExists(t, link)
+	})
+
+	t.Run("RealFile", func(t *testing.T) {
+		tempDir := t.TempDir()
+		link := path.Join(tempDir, "link")
+		require.NoError(t, os.WriteFile(link, []byte("not a link"), 0644))
+
+		require.Error(t, ensureSymlink(NewOSFilesystem(), "/some/target", link))
+		assert.FileExists(t, link)
+	})
+}
@@ -0,0 +1,257 @@
+package organize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// RepoStatus describes an organized repo after it has been synced.
+type RepoStatus struct {
+	Path string
+
+	// Branch is the branch checked out in the repo, or empty if HEAD is detached.
+	Branch string
+
+	// Upstream is the remote tracking branch Branch is compared to, or empty if it has none.
+	Upstream string
+
+	// Ahead and Behind are the number of commits Branch and Upstream have which the other does not.
+	Ahead  int
+	Behind int
+
+	Dirty bool
+
+	// LastFetch is when the repo was last fetched, or the zero time if it is not known.
+	LastFetch time.Time
+
+	// Err holds any errors encountered while syncing the repo.
+	Err error
+}
+
+// upstreamRef returns the name of the remote tracking branch for branch, falling back to the same branch of
+// the primary remote when none is configured.
+func upstreamRef(repo *git.Repository, branch string, primary string) (plumbing.ReferenceName, error) {
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", err
+	}
+
+	if b, found := cfg.Branches[branch]; found && b.Remote != "" && b.Merge.IsBranch() {
+		return plumbing.NewRemoteReferenceName(b.Remote, b.Merge.Short()), nil
+	}
+
+	return plumbing.NewRemoteReferenceName(primary, branch), nil
+}
+
+// ancestors returns the hashes of every commit reachable from hash.
+func ancestors(repo *git.Repository, hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	err = object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(c *object.Commit) error {
+		seen[c.Hash] = true
+		return nil
+	})
+
+	return seen, err
+}
+
+// aheadBehind counts the commits reachable from local but not upstream, and from upstream but not local.
+func aheadBehind(repo *git.Repository, local plumbing.Hash, upstream plumbing.Hash) (int, int, error) {
+	localCommits, err := ancestors(repo, local)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	upstreamCommits, err := ancestors(repo, upstream)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var ahead, behind int
+	for hash := range localCommits {
+		if !upstreamCommits[hash] {
+			ahead++
+		}
+	}
+
+	for hash := range upstreamCommits {
+		if !localCommits[hash] {
+			behind++
+		}
+	}
+
+	return ahead, behind, nil
+}
+
+// fetchAll fetches every remote of repo, returning any errors once all remotes have been tried.
+func fetchAll(ctx context.Context, repo *git.Repository) error {
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, remote := range remotes {
+		if len(remote.Config().URLs) == 0 {
+			continue
+		}
+
+		err := remote.FetchContext(ctx, &git.FetchOptions{})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			errs = append(errs, fmt.Errorf("could not fetch remote '%s': %w", remote.Config().Name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// lastRemoteUpdate returns when a remote tracking ref of repo was last changed, or the zero time if it has
+// none. This is when the repo was last fetched, unless later fetches found nothing new or the refs have since
+// been packed.
+func lastRemoteUpdate(repo *git.Repository) time.Time {
+	storage, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return time.Time{}
+	}
+
+	var last time.Time
+	_ = util.Walk(storage.Filesystem(), "refs/remotes", func(p string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() && info.ModTime().After(last) {
+			last = info.ModTime()
+		}
+		return nil
+	})
+
+	return last
+}
+
+// syncRepo fetches every remote of the repo at repoPath and describes its state afterwards.
+func (o *Organizer) syncRepo(ctx context.Context, repoPath string) RepoStatus {
+	status := RepoStatus{Path: repoPath}
+
+	repo, err := openRepo(o.fs, repoPath)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+
+	var errs []error
+
+	if err := fetchAll(ctx, repo); err != nil {
+		errs = append(errs, err)
+		status.LastFetch = lastRemoteUpdate(repo)
+	} else {
+		status.LastFetch = time.Now()
+	}
+
+	if worktree, err := repo.Worktree(); err == nil {
+		if worktreeStatus, err := worktree.Status(); err != nil {
+			errs = append(errs, fmt.Errorf("could not get worktree status: %w", err))
+		} else {
+			status.Dirty = !worktreeStatus.IsClean()
+		}
+	} else if !errors.Is(err, git.ErrIsBareRepository) {
+		errs = append(errs, err)
+	}
+
+	head, err := repo.Head()
+	if err != nil || !head.Name().IsBranch() {
+		status.Err = errors.Join(errs...)
+		return status
+	}
+
+	status.Branch = head.Name().Short()
+
+	upstreamName, err := upstreamRef(repo, status.Branch, o.config.primaryRemote())
+	if err != nil {
+		status.Err = errors.Join(append(errs, err)...)
+		return status
+	}
+
+	upstream, err := repo.Reference(upstreamName, true)
+	if err == nil {
+		status.Upstream = upstreamName.Short()
+		status.Ahead, status.Behind, err = aheadBehind(repo, head.Hash(), upstream.Hash())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not compare '%s' to '%s': %w", status.Branch, status.Upstream, err))
+		}
+	} else if !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		errs = append(errs, err)
+	}
+
+	status.Err = errors.Join(errs...)
+
+	return status
+}
+
+// Sync fetches every remote of every organized repo, using up to jobs repos at a time, and returns the status
+// of each repo afterwards.
+func (o *Organizer) Sync(ctx context.Context, jobs int) ([]RepoStatus, error) {
+	var repoPaths []string
+
+	_, err := o.walkOrganized(ctx, func(repoPath string) error {
+		repoPaths = append(repoPaths, repoPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]RepoStatus, len(repoPaths))
+
+	parallel(len(repoPaths), jobs, func(i int) {
+		o.logger.Printf("syncing repo '%s'", repoPaths[i])
+
+		statuses[i] = o.syncRepo(ctx, repoPaths[i])
+		if statuses[i].Err != nil {
+			o.logger.Printf("ERROR: could not sync repo '%s': %s", repoPaths[i], statuses[i].Err)
+		}
+	})
+
+	return statuses, nil
+}
+
+// WriteStatuses writes statuses to w as a table.
+func WriteStatuses(w io.Writer, statuses []RepoStatus) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "PATH\tBRANCH\tUPSTREAM\tAHEAD\tBEHIND\tDIRTY\tLAST FETCH\tERROR")
+
+	for _, status := range statuses {
+		lastFetch := "never"
+		if !status.LastFetch.IsZero() {
+			lastFetch = status.LastFetch.Format("2006-01-02 15:04")
+		}
+
+		dirty := ""
+		if status.Dirty {
+			dirty = "*"
+		}
+
+		errMsg := ""
+		if status.Err != nil {
+			errMsg = strings.ReplaceAll(status.Err.Error(), "\n", "; ")
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\t%s\n", status.Path, status.Branch, status.Upstream,
+			status.Ahead, status.Behind, dirty, lastFetch, errMsg)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,91 @@
+package organize
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func commitFile(t *testing.T, repo *git.Repository, dir string, name string, contents string) {
+	require.NoError(t, os.WriteFile(path.Join(dir, name), []byte(contents), 0644))
+
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	_, err = wt.Add(name)
+	require.NoError(t, err)
+
+	_, err = wt.Commit("add "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+}
+
+func TestSync(t *testing.T) {
+	tempDir := t.TempDir()
+	upstream := BareUpstream(t, tempDir)
+
+	cfg := NewDefaultConfig()
+	cfg.Destination = path.Join(tempDir, "destination")
+
+	repoDir := path.Join(cfg.Destination, "originuser", "origin")
+	repo, err := git.PlainClone(repoDir, false, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+
+	// someone else pushes a change upstream
+	otherDir := path.Join(tempDir, "other")
+	other, err := git.PlainClone(otherDir, false, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+	commitFile(t, other, otherDir, "other.txt", "from someone else")
+	require.NoError(t, other.Push(&git.PushOptions{}))
+
+	commitFile(t, repo, repoDir, "local.txt", "not pushed yet")
+	require.NoError(t, os.WriteFile(path.Join(repoDir, "untracked.txt"), nil, 0644))
+
+	_, err = git.PlainClone(path.Join(cfg.Destination, "originuser", "mirror.git"), true, &git.CloneOptions{URL: upstream})
+	require.NoError(t, err)
+
+	statuses, err := NewOrganizer(WithConfig(cfg)).Sync(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+
+	bare := statuses[0]
+	assert.NoError(t, bare.Err)
+	assert.Equal(t, "master", bare.Branch)
+	assert.False(t, bare.Dirty)
+
+	status := statuses[1]
+	assert.NoError(t, status.Err)
+	assert.Equal(t, repoDir, status.Path)
+	assert.Equal(t, "master", status.Branch)
+	assert.Equal(t, "origin/master", status.Upstream)
+	assert.Equal(t, 1, status.Ahead)
+	assert.Equal(t, 1, status.Behind)
+	assert.True(t, status.Dirty)
+	assert.False(t, status.LastFetch.IsZero())
+
+	var out bytes.Buffer
+	require.NoError(t, WriteStatuses(&out, statuses))
+	assert.Contains(t, out.String(), "origin/master  1      1       *")
+
+	// once upstream is gone, the last fetch is when the remote tracking refs were last updated
+	fetched := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	require.NoError(t, os.Chtimes(path.Join(repoDir, ".git", "refs", "remotes", "origin", "master"), fetched, fetched))
+	require.NoError(t, os.RemoveAll(upstream))
+
+	statuses, err = NewOrganizer(WithConfig(cfg)).Sync(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+
+	status = statuses[1]
+	assert.Error(t, status.Err)
+	assert.True(t, fetched.Equal(status.LastFetch), status.LastFetch)
+}
@@ -1,9 +1,15 @@
 package organize
 
 import (
+	"fmt"
+	"os"
 	"path"
+	"strings"
+	"time"
 
+	"github.com/go-git/go-billy/v5"
 	"golang.org/x/exp/slices"
+	"gopkg.in/yaml.v3"
 )
 
 // MultipleRemoteStrategy defines how to organize repos that have multiple remotes.
@@ -13,11 +19,10 @@ const (
 	// StrategyDefault will organize the repository using the default strategy.
 	StrategyDefault MultipleRemoteStrategy = ""
 
-	// StrategyOrigin will organize the repository using only the fetch remote.
-	// todo: move towards a default remote rather than hardcoding the origin remote
+	// StrategyOrigin will organize the repository using only the primary remote.
 	StrategyOrigin MultipleRemoteStrategy = "origin"
 
-	// StrategySymlink will organize the repository using symbolic links to the fetch remote.
+	// StrategySymlink will organize the repository using symbolic links to the primary remote.
 	StrategySymlink MultipleRemoteStrategy = "symlink"
 
 	// StrategyQuarantine will organize the repository by placing it in the quarantine director if multiple remotes are found.
@@ -25,25 +30,108 @@ const (
 )
 
 type Config struct {
-	Destination string
+	Destination string `yaml:"destination"`
+
+	// BareDestination is the top level directory where bare repositories and mirrors will be organized
+	// into. If BareDestination is relative, it will be relative to Destination. If BareDestination is
+	// empty, Destination is used.
+	BareDestination string `yaml:"bare-destination"`
 
 	// Stage is the directory where the repos will be staged before being organized into Destination. If
 	// if Stage is relative, it will be relative to Destination. If no error is encountered when
-	// organinzing a repo, the staging dir will be removed. Otherwise, it will be left in place.
-	Stage string
+	// organinzing a repo and the organized copy matches the original, the staging dir will be removed.
+	// Otherwise, it will be left in place.
+	Stage string `yaml:"stage"`
 
 	// Quarantine is the directory where repos that could not be organized will be placed. If Quarantine
 	// is relative, it will be relative to Destination.
-	Quarantine string
+	Quarantine string `yaml:"quarantine"`
+
+	// Unsorted is the directory where directories that are not git repositories, or repositories without
+	// any remotes, will be moved. If Unsorted is relative, it will be relative to Destination. If Unsorted is
+	// empty, those directories are left in place.
+	Unsorted string `yaml:"unsorted"`
+
+	// Broken is the directory where repositories that could not be opened will be moved. If Broken is
+	// relative, it will be relative to Destination. If Broken is empty, broken repositories are left in
+	// place.
+	Broken string `yaml:"broken"`
 
 	// IncludeRemotes specifies which remotes to include. If IncludeRemotes is empty, all remotes are included. IncludeRemotes
-	// takes precedence over Exclude, so any remote in both will be included.
-	IncludeRemotes []string
+	// takes precedence over Exclude, so any remote in both will be included, and ExcludeRemotes is ignored
+	// whenever IncludeRemotes is not empty.
+	//
+	// Each entry is a pattern written as '[field:]pattern', where field is 'name', 'url', or 'host' and
+	// defaults to 'name'. Patterns are globs as used by path.Match, or unanchored regular expressions if they
+	// start with 're:'. For example 'up*', 'host:github.com', or 'url:re:^https://'. A remote matches a
+	// url or host pattern if any of its urls matches.
+	IncludeRemotes []string `yaml:"include-remotes"`
+
+	// ExcludeRemotes specifies which remotes to exclude, using the same patterns as IncludeRemotes. If
+	// ExcludeRemotes is empty, no remotes are excluded.
+	ExcludeRemotes []string `yaml:"exclude-remotes"`
+
+	RemoteStrategy MultipleRemoteStrategy `yaml:"remote-strategy"`
+
+	// PrimaryRemote is the name of the remote repos are organized by. If PrimaryRemote is empty, "origin" is
+	// used.
+	PrimaryRemote string `yaml:"primary-remote"`
+
+	// HostRoots maps remote hosts to the top level directory repos from that host will be organized into
+	// instead of Destination. Relative roots are relative to Destination. Bare repos are not affected.
+	HostRoots map[string]string `yaml:"host-roots"`
+
+	// OwnerAliases maps remote owners to the name of the directory their repos will be organized into. Keys
+	// are compared against owners after normalization.
+	OwnerAliases map[string]string `yaml:"owner-aliases"`
+
+	// Normalize controls how the owner and name of remotes are normalized.
+	Normalize Normalization `yaml:"normalize"`
+
+	// RelativeLinks will create symlinks relative to the link's directory rather than with absolute paths,
+	// so they continue to work if Destination is moved.
+	RelativeLinks bool `yaml:"relative-links"`
+
+	// Ignore is a list of glob patterns, as used by path.Match, for the names of directories which should
+	// not be organized. Directories are also ignored if they match an input directory's IgnoreFileName.
+	Ignore []string `yaml:"ignore"`
 
-	// ExcludeRemotes specifies which remotes to exclude. If ExcludeRemotes is empty, no remotes are excluded.
-	ExcludeRemotes []string
+	// BackupDir is the directory where a git bundle of every ref of each repo, and a tarball of its untracked
+	// and ignored files, are written before it is organized. If BackupDir is relative, it will be relative
+	// to Destination. If BackupDir is empty, no backups are made.
+	BackupDir string `yaml:"backup-dir"`
 
-	RemoteStrategy MultipleRemoteStrategy
+	// BackupKeep is how many backups of each repo are kept. If BackupKeep is 0, backups are not pruned by
+	// count.
+	BackupKeep int `yaml:"backup-keep"`
+
+	// BackupMaxAge is how long backups are kept for. If BackupMaxAge is 0, backups are not pruned by age.
+	BackupMaxAge time.Duration `yaml:"backup-max-age"`
+
+	// VerifyConnectivity will check every object reachable from an organized repo's refs exists before its
+	// staged copy is removed, in addition to comparing its refs, objects, and files with the original.
+	VerifyConnectivity bool `yaml:"verify-connectivity"`
+
+	// RelinkWorktrees will point linked worktrees outside of a repo at the repo's organized copy. By default
+	// they are left linked to the original repo, and the organized copy only records where they are.
+	RelinkWorktrees bool `yaml:"relink-worktrees"`
+
+	// Hooks are shell commands run with `sh -c` after each repo is organized. The repo's old and new paths,
+	// owner, name, and remote strategy are passed in the ORGANIZE_OLD_PATH, ORGANIZE_NEW_PATH,
+	// ORGANIZE_OWNER, ORGANIZE_NAME, and ORGANIZE_STRATEGY environment variables.
+	Hooks []string `yaml:"hooks"`
+
+	// Where is an expression selecting which repos are organized and acted on by other commands, such as
+	// `host == "github.com" && !dirty`. See Filter for the syntax. If Where is empty every repo is selected.
+	Where string `yaml:"where"`
+
+	// RewriteRemotes changes the urls and names of the remotes of each repo once it is organized. Every change
+	// is recorded in the report. By default remotes are left unchanged.
+	RewriteRemotes RemoteRewrites `yaml:"rewrite-remotes"`
+
+	// Rules route repos to different destinations or strategies. The first rule matching a repo is used,
+	// and repos not matching any rule are organized using the rest of Config.
+	Rules []Rule `yaml:"rules"`
 }
 
 func NewDefaultConfig() Config {
@@ -54,20 +142,189 @@ func NewDefaultConfig() Config {
 		IncludeRemotes: []string{},
 		ExcludeRemotes: []string{},
 		RemoteStrategy: StrategyDefault,
+		PrimaryRemote:  "origin",
+		Ignore:         []string{},
+		Hooks:          []string{},
+		HostRoots:      map[string]string{},
+		OwnerAliases:   map[string]string{},
 	}
 }
 
+// LoadConfig reads a yaml config file at p. Any values not set in the file are left as their defaults.
+func LoadConfig(p string) (Config, error) {
+	config := NewDefaultConfig()
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return config, err
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("could not parse config file '%s': %w", p, err)
+	}
+
+	return config, nil
+}
+
+// Validate checks config for values which would cause every repo to fail to be organized.
+func (config Config) Validate() error {
+	strategies := []MultipleRemoteStrategy{StrategyDefault, StrategyOrigin, StrategySymlink, StrategyQuarantine}
+
+	if !slices.Contains(strategies, config.RemoteStrategy) {
+		return fmt.Errorf("unsupported remote strategy '%s'", config.RemoteStrategy)
+	}
+
+	if err := validateRemotePatterns(config.IncludeRemotes); err != nil {
+		return fmt.Errorf("invalid include-remotes: %w", err)
+	}
+
+	if err := validateRemotePatterns(config.ExcludeRemotes); err != nil {
+		return fmt.Errorf("invalid exclude-remotes: %w", err)
+	}
+
+	if config.Where != "" {
+		if _, err := ParseFilter(config.Where); err != nil {
+			return err
+		}
+	}
+
+	if err := config.RewriteRemotes.validate(); err != nil {
+		return fmt.Errorf("invalid rewrite-remotes: %w", err)
+	}
+
+	for i, rule := range config.Rules {
+		if !slices.Contains(strategies, rule.RemoteStrategy) {
+			return fmt.Errorf("rule %d has unsupported remote strategy '%s'", i, rule.RemoteStrategy)
+		}
+
+		if err := rule.Match.validate(); err != nil {
+			return fmt.Errorf("rule %d is invalid: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (config Config) primaryRemote() string {
+	if config.PrimaryRemote == "" {
+		return "origin"
+	}
+
+	return config.PrimaryRemote
+}
+
+// IsRemoteAllowed returns true if a remote with only the given name is allowed by IncludeRemotes and
+// ExcludeRemotes. Url and host patterns never match it, use IsRemoteURLAllowed if the urls are known.
 func (config Config) IsRemoteAllowed(remote string) bool {
+	return config.IsRemoteURLAllowed(remote, nil)
+}
+
+// IsRemoteURLAllowed returns true if the remote with the given name and urls is allowed by IncludeRemotes
+// and ExcludeRemotes.
+func (config Config) IsRemoteURLAllowed(remote string, urls []string) bool {
 	if len(config.IncludeRemotes) != 0 {
-		return slices.Contains(config.IncludeRemotes, remote)
+		return matchRemote(config.IncludeRemotes, remote, urls)
+	}
+	return !matchRemote(config.ExcludeRemotes, remote, urls)
+}
+
+// expandHome replaces a leading '~' in p with the current user's home directory.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return path.Join(home, strings.TrimPrefix(p, "~"))
+		}
+	}
+
+	return p
+}
+
+// DestinationPath returns Destination with a leading '~' replaced with the current user's home directory.
+func (config Config) DestinationPath() string {
+	return expandHome(config.Destination)
+}
+
+// resolve returns p if it is absolute, or p relative to Destination otherwise. A leading '~' is replaced
+// with the current user's home directory.
+func (config Config) resolve(p string) string {
+	p = expandHome(p)
+
+	if path.IsAbs(p) {
+		return p
+	}
+
+	return path.Clean(path.Join(config.DestinationPath(), p))
+}
+
+// BareDestinationPath returns the top level directory for bare repositories.
+func (config Config) BareDestinationPath() string {
+	if config.BareDestination == "" {
+		return config.DestinationPath()
+	}
+
+	return config.resolve(config.BareDestination)
+}
+
+// HostRootPath returns the top level directory for working copies from host.
+func (config Config) HostRootPath(host string) string {
+	if root, found := config.HostRoots[host]; found {
+		return config.resolve(root)
 	}
-	return !slices.Contains(config.ExcludeRemotes, remote)
+
+	return config.DestinationPath()
+}
+
+func (config Config) StagePath() string {
+	return config.resolve(config.Stage)
 }
 
 func (config Config) QuarantinePath() string {
-	if path.IsAbs(config.Quarantine) {
-		return config.Quarantine
+	return config.resolve(config.Quarantine)
+}
+
+// UnsortedPath returns the path to the unsorted bucket, or an empty string if it is disabled.
+func (config Config) UnsortedPath() string {
+	if config.Unsorted == "" {
+		return ""
+	}
+
+	return config.resolve(config.Unsorted)
+}
+
+// BrokenPath returns the path to the broken bucket, or an empty string if it is disabled.
+func (config Config) BrokenPath() string {
+	if config.Broken == "" {
+		return ""
+	}
+
+	return config.resolve(config.Broken)
+}
+
+// BackupPath returns the path to the backup directory, or an empty string if backups are disabled.
+func (config Config) BackupPath() string {
+	if config.BackupDir == "" {
+		return ""
+	}
+
+	return config.resolve(config.BackupDir)
+}
+
+// IsManagedPath returns true if p is one of the directories organize places repos into which are not
+// organized by remote, and so should never be organized itself.
+func (config Config) IsManagedPath(p string) bool {
+	return config.isManagedPath(NewOSFilesystem(), p)
+}
+
+// isManagedPath is IsManagedPath for a path in fs.
+func (config Config) isManagedPath(fs billy.Filesystem, p string) bool {
+	managed := []string{config.StagePath(), config.QuarantinePath(), config.UnsortedPath(), config.BrokenPath(), config.BackupPath()}
+
+	abs := absPath(fs, p)
+	for _, m := range managed {
+		if m != "" && absPath(fs, m) == abs {
+			return true
+		}
 	}
 
-	return path.Clean(path.Join(config.Destination, config.Quarantine))
+	return false
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/go-git/go-git/v5"
 )
@@ -16,7 +17,7 @@ func getRemoteOwnerAndNameFromHttp(s string) (string, string, error) {
 
 	components := strings.SplitN(u.Path, "/", 3)
 
-	if len(components) < 3 {
+	if len(components) < 3 || components[1] == "" || strings.TrimSuffix(components[2], ".git") == "" {
 		return "", "", fmt.Errorf("url did not contain enough path components")
 	}
 
@@ -28,24 +29,83 @@ func getRemoteOwnerAndNameFromSSH(s string) (string, string, error) {
 	trimmed := strings.TrimSuffix(path, ".git")
 	components := strings.Split(trimmed, "/")
 
-	if len(components) < 2 {
+	if len(components) < 2 || components[0] == "" || components[1] == "" {
 		return "", "", fmt.Errorf("address did not contain enough path components")
 	}
 
 	return components[0], components[1], nil
 }
 
+// getRemoteHost returns the host of the remote's first url.
+func getRemoteHost(r *git.Remote) (string, error) {
+	if len(r.Config().URLs) == 0 {
+		return "", fmt.Errorf("remote '%s' has not urls", r.Config().Name)
+	}
+
+	host, err := urlHost(r.Config().URLs[0])
+	if err != nil {
+		return "", fmt.Errorf("remote '%s' has an invalid url: %w", r.Config().Name, err)
+	}
+
+	return host, nil
+}
+
+// urlHost returns the host of a remote url.
+func urlHost(u string) (string, error) {
+	switch {
+	case strings.Contains(u, "http") || strings.HasPrefix(u, "ssh://"):
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return "", fmt.Errorf("could not parse url for remote: %w", err)
+		}
+		return parsed.Hostname(), nil
+	case strings.Contains(u, "@") || isSCPLike(u):
+		host := strings.SplitN(u, ":", 2)[0]
+		return host[strings.LastIndex(host, "@")+1:], nil
+	default:
+		return "", fmt.Errorf("unsupported url '%s'", u)
+	}
+}
+
 func getRemoteOwnerAndName(r *git.Remote) (string, string, error) {
 	if len(r.Config().URLs) == 0 {
 		return "", "", fmt.Errorf("remote '%s' has not urls", r.Config().Name)
 	}
 
 	switch u := r.Config().URLs[0]; {
-	case strings.Contains(u, "http"):
+	case strings.Contains(u, "http") || strings.HasPrefix(u, "ssh://"):
 		return getRemoteOwnerAndNameFromHttp(u)
-	case strings.Contains(u, "@"):
+	case strings.Contains(u, "@") || isSCPLike(u):
 		return getRemoteOwnerAndNameFromSSH(u)
 	default:
 		return "", "", fmt.Errorf("remote '%s' has an invalid url: %s", r.Config().Name, u)
 	}
 }
+
+// parallel calls fn for each index in [0, n) using up to jobs goroutines, and waits for them all to return.
+func parallel(n int, jobs int, fn func(i int)) {
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	wg.Wait()
+}
@@ -1,6 +1,8 @@
 package organize
 
 import (
+	"os"
+	"path"
 	"testing"
 
 	"github.com/go-git/go-git/v5"
@@ -36,6 +38,89 @@ func TestIsRemoteAllowed(t *testing.T) {
 		assert.True(t, config.IsRemoteAllowed("origin"))
 		assert.False(t, config.IsRemoteAllowed("upstream"))
 	})
+
+	t.Run("NamePatterns", func(t *testing.T) {
+		config := Config{
+			ExcludeRemotes: []string{"up*", "name:re:^fork-[0-9]+$"},
+		}
+		assert.False(t, config.IsRemoteAllowed("upstream"))
+		assert.False(t, config.IsRemoteAllowed("fork-12"))
+		assert.True(t, config.IsRemoteAllowed("fork-a"))
+		assert.True(t, config.IsRemoteAllowed("origin"))
+	})
+
+	t.Run("HostPatterns", func(t *testing.T) {
+		config := Config{
+			ExcludeRemotes: []string{"host:*.internal.example.com"},
+		}
+		assert.False(t, config.IsRemoteURLAllowed("origin", []string{"git@gitlab.internal.example.com:owner/repo.git"}))
+		assert.False(t, config.IsRemoteURLAllowed("origin", []string{"https://github.com/owner/repo.git", "https://git.internal.example.com/owner/repo.git"}))
+		assert.True(t, config.IsRemoteURLAllowed("origin", []string{"https://github.com/owner/repo.git"}))
+
+		// host patterns cannot match a remote without urls
+		config = Config{IncludeRemotes: []string{"host:github.com"}}
+		assert.True(t, config.IsRemoteURLAllowed("origin", []string{"git@github.com:owner/repo.git"}))
+		assert.False(t, config.IsRemoteURLAllowed("origin", []string{"https://gitlab.com/owner/repo.git"}))
+		assert.False(t, config.IsRemoteAllowed("origin"))
+	})
+
+	t.Run("URLPatterns", func(t *testing.T) {
+		config := Config{
+			IncludeRemotes: []string{"url:re:^https://github\\.com/rancher/", "url:git@github.com:*/*"},
+		}
+		assert.True(t, config.IsRemoteURLAllowed("origin", []string{"https://github.com/rancher/rancher.git"}))
+		assert.True(t, config.IsRemoteURLAllowed("origin", []string{"git@github.com:owner/repo.git"}))
+		assert.False(t, config.IsRemoteURLAllowed("origin", []string{"https://github.com/owner/repo.git"}))
+	})
+
+	t.Run("PatternPrecedence", func(t *testing.T) {
+		config := Config{
+			IncludeRemotes: []string{"host:github.com"},
+			ExcludeRemotes: []string{"origin", "url:*"},
+		}
+		assert.True(t, config.IsRemoteURLAllowed("origin", []string{"https://github.com/owner/repo.git"}))
+		assert.False(t, config.IsRemoteURLAllowed("upstream", []string{"https://gitlab.com/owner/repo.git"}))
+	})
+
+	t.Run("BadPatterns", func(t *testing.T) {
+		assert.Error(t, Config{IncludeRemotes: []string{"re:("}}.Validate())
+		assert.Error(t, Config{ExcludeRemotes: []string{"host:["}}.Validate())
+		assert.NoError(t, Config{IncludeRemotes: []string{"origin"}, ExcludeRemotes: []string{"url:re:^ssh://"}}.Validate())
+
+		// a pattern which cannot be parsed never matches
+		assert.True(t, Config{ExcludeRemotes: []string{"["}}.IsRemoteAllowed("["))
+	})
+}
+
+func TestGetRemoteHost(t *testing.T) {
+	t.Run("SSH", func(t *testing.T) {
+		remote := git.NewRemote(nil, &config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{"git@github.com:joshmeranda/MyJournal.git"},
+		})
+		host, err := getRemoteHost(remote)
+		require.NoError(t, err)
+		assert.Equal(t, "github.com", host)
+	})
+
+	t.Run("HTTP", func(t *testing.T) {
+		remote := git.NewRemote(nil, &config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{"https://gitlab.internal:8443/joshmeranda/MyJournal.git"},
+		})
+		host, err := getRemoteHost(remote)
+		require.NoError(t, err)
+		assert.Equal(t, "gitlab.internal", host)
+	})
+
+	t.Run("BadUrl", func(t *testing.T) {
+		remote := git.NewRemote(nil, &config.RemoteConfig{
+			Name: "origin",
+			URLs: []string{"github.com/joshmeranda"},
+		})
+		_, err := getRemoteHost(remote)
+		assert.Error(t, err)
+	})
 }
 
 func TestGetRemoteOwnereAndName(t *testing.T) {
@@ -51,6 +136,17 @@ func TestGetRemoteOwnereAndName(t *testing.T) {
 			assert.Equal(t, "MyJournal", name)
 		})
 
+		t.Run("NoUser", func(t *testing.T) {
+			remote := git.NewRemote(nil, &config.RemoteConfig{
+				Name: "origin",
+				URLs: []string{"github.com:joshmeranda/MyJournal.git"},
+			})
+			owner, name, err := getRemoteOwnerAndName(remote)
+			require.NoError(t, err)
+			assert.Equal(t, "joshmeranda", owner)
+			assert.Equal(t, "MyJournal", name)
+		})
+
 		t.Run("MissingUserName", func(t *testing.T) {
 			remote := git.NewRemote(nil, &config.RemoteConfig{
 				Name: "origin",
@@ -74,6 +170,34 @@ func TestGetRemoteOwnereAndName(t *testing.T) {
 		})
 	})
 
+	t.Run("SSHURL", func(t *testing.T) {
+		t.Run("OK", func(t *testing.T) {
+			remote := git.NewRemote(nil, &config.RemoteConfig{
+				Name: "origin",
+				URLs: []string{"ssh://git@github.com:2222/joshmeranda/MyJournal.git"},
+			})
+			owner, name, err := getRemoteOwnerAndName(remote)
+			require.NoError(t, err)
+			assert.Equal(t, "joshmeranda", owner)
+			assert.Equal(t, "MyJournal", name)
+
+			host, err := getRemoteHost(remote)
+			require.NoError(t, err)
+			assert.Equal(t, "github.com", host)
+		})
+
+		t.Run("MissingName", func(t *testing.T) {
+			remote := git.NewRemote(nil, &config.RemoteConfig{
+				Name: "origin",
+				URLs: []string{"ssh://git@github.com/MyJournal.git"},
+			})
+			owner, name, err := getRemoteOwnerAndName(remote)
+			assert.Error(t, err)
+			assert.Equal(t, "", owner)
+			assert.Equal(t, "", name)
+		})
+	})
+
 	t.Run("HTTP", func(t *testing.T) {
 		t.Run("OK", func(t *testing.T) {
 			remote := git.NewRemote(nil, &config.RemoteConfig{
@@ -120,3 +244,66 @@ func TestGetRemoteOwnereAndName(t *testing.T) {
 		})
 	})
 }
+
+func TestIsManagedPath(t *testing.T) {
+	config := NewDefaultConfig()
+	config.Destination = "/tmp/destination"
+	config.Unsorted = "unsorted"
+
+	assert.True(t, config.IsManagedPath("/tmp/destination/.stage"))
+	assert.True(t, config.IsManagedPath("/tmp/destination/quarantine/"))
+	assert.True(t, config.IsManagedPath("/tmp/destination/unsorted"))
+	assert.False(t, config.IsManagedPath("/tmp/destination/broken"))
+	assert.False(t, config.IsManagedPath("/tmp/destination/some-repo"))
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		p := path.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(p, []byte(`destination: ~/src
+remote-strategy: origin
+host-roots:
+  gitlab.internal: ~/work
+owner-aliases:
+  rancher-sandbox: rancher
+normalize:
+  lowercase: true
+rules:
+  - match:
+      host: gitlab.internal
+    destination: ~/work
+    remote-strategy: symlink
+`), 0644))
+
+		config, err := LoadConfig(p)
+		require.NoError(t, err)
+		require.NoError(t, config.Validate())
+
+		home, err := os.UserHomeDir()
+		require.NoError(t, err)
+
+		assert.Equal(t, "~/src", config.Destination)
+		assert.Equal(t, path.Join(home, "src"), config.DestinationPath())
+		assert.Equal(t, path.Join(home, "src"), config.HostRootPath("github.com"))
+		assert.Equal(t, path.Join(home, "src", ".stage"), config.StagePath())
+		assert.Equal(t, ".stage", config.Stage)
+		assert.Equal(t, StrategyOrigin, config.RemoteStrategy)
+		assert.Equal(t, path.Join(home, "work"), config.HostRootPath("gitlab.internal"))
+		assert.Equal(t, map[string]string{"rancher-sandbox": "rancher"}, config.OwnerAliases)
+		assert.True(t, config.Normalize.Lowercase)
+		assert.Equal(t, []Rule{{
+			Match:          RuleMatch{Host: "gitlab.internal"},
+			Destination:    "~/work",
+			RemoteStrategy: StrategySymlink,
+		}}, config.Rules)
+	})
+
+	t.Run("BadStrategy", func(t *testing.T) {
+		p := path.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(p, []byte("rules:\n  - remote-strategy: nonsense\n"), 0644))
+
+		config, err := LoadConfig(p)
+		require.NoError(t, err)
+		assert.Error(t, config.Validate())
+	})
+}
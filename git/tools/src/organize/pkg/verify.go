@@ -0,0 +1,250 @@
+package organize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/revlist"
+)
+
+// ErrVerificationFailed is returned when an organized repo does not match the repo it was copied from.
+var ErrVerificationFailed = errors.New("organized repo does not match the original")
+
+// maxDifferences is the most differences described when verification fails.
+const maxDifferences = 10
+
+// repoSnapshot summarizes a repo so that copies of it can be compared.
+type repoSnapshot struct {
+	head    string
+	refs    map[plumbing.ReferenceName]string
+	objects int
+
+	// files maps the path of each file in the working tree to a checksum of its contents.
+	files map[string]string
+}
+
+// checksumFile returns a checksum of the file at p, or of its target if it is a symlink.
+func checksumFile(fs billy.Filesystem, p string, info os.FileInfo) (string, error) {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := fs.Readlink(p)
+		if err != nil {
+			return "", err
+		}
+
+		return "symlink:" + target, nil
+	}
+
+	f, err := fs.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// checksumWorktree returns a checksum manifest of every file in the working tree at dir, excluding any git
+// directories or files.
+func checksumWorktree(fs billy.Filesystem, dir string) (map[string]string, error) {
+	files := make(map[string]string)
+
+	err := util.Walk(fs, dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Name() == ".git" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() || (!info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		files[rel], err = checksumFile(fs, p, info)
+		return err
+	})
+
+	return files, err
+}
+
+// snapshotRepo opens the repo at repoPath and summarizes it.
+func snapshotRepo(fs billy.Filesystem, repoPath string) (repoSnapshot, error) {
+	snapshot := repoSnapshot{refs: make(map[plumbing.ReferenceName]string)}
+
+	repo, err := openRepo(fs, repoPath)
+	if err != nil {
+		return snapshot, fmt.Errorf("could not open '%s': %w", repoPath, err)
+	}
+
+	head, err := repo.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return snapshot, fmt.Errorf("could not read HEAD of '%s': %w", repoPath, err)
+	}
+	snapshot.head = head.String()
+
+	refs, err := repo.References()
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		snapshot.refs[ref.Name()] = ref.String()
+		return nil
+	})
+	if err != nil {
+		return snapshot, fmt.Errorf("could not read refs of '%s': %w", repoPath, err)
+	}
+
+	objects, err := repo.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return snapshot, err
+	}
+
+	err = objects.ForEach(func(plumbing.EncodedObject) error {
+		snapshot.objects++
+		return nil
+	})
+	if err != nil {
+		return snapshot, fmt.Errorf("could not read objects of '%s': %w", repoPath, err)
+	}
+
+	if _, err := repo.Worktree(); errors.Is(err, git.ErrIsBareRepository) {
+		return snapshot, nil
+	}
+
+	if snapshot.files, err = checksumWorktree(fs, repoPath); err != nil {
+		return snapshot, fmt.Errorf("could not checksum the working tree of '%s': %w", repoPath, err)
+	}
+
+	return snapshot, nil
+}
+
+// compareMaps describes how the values in actual differ from those in expected.
+func compareMaps[K ~string](kind string, expected map[K]string, actual map[K]string) []string {
+	var differences []string
+
+	for key, value := range expected {
+		if actualValue, found := actual[key]; !found {
+			differences = append(differences, fmt.Sprintf("%s '%s' is missing", kind, key))
+		} else if actualValue != value {
+			differences = append(differences, fmt.Sprintf("%s '%s' differs", kind, key))
+		}
+	}
+
+	for key := range actual {
+		if _, found := expected[key]; !found {
+			differences = append(differences, fmt.Sprintf("unexpected %s '%s'", kind, key))
+		}
+	}
+
+	sort.Strings(differences)
+
+	return differences
+}
+
+// compare describes how actual differs from expected.
+func (expected repoSnapshot) compare(actual repoSnapshot) []string {
+	var differences []string
+
+	if expected.head != actual.head {
+		differences = append(differences, fmt.Sprintf("HEAD is '%s', expected '%s'", actual.head, expected.head))
+	}
+
+	if expected.objects != actual.objects {
+		differences = append(differences, fmt.Sprintf("found %d objects, expected %d", actual.objects, expected.objects))
+	}
+
+	differences = append(differences, compareMaps("ref", expected.refs, actual.refs)...)
+	differences = append(differences, compareMaps("file", expected.files, actual.files)...)
+
+	return differences
+}
+
+// checkConnectivity checks every object reachable from the refs of the repo at repoPath exists, similar to
+// `git fsck --connectivity-only`.
+func checkConnectivity(fs billy.Filesystem, repoPath string) error {
+	repo, err := openRepo(fs, repoPath)
+	if err != nil {
+		return err
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+
+	var hashes []plumbing.Hash
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			hashes = append(hashes, ref.Hash())
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the history of shallow repos is expected to be incomplete
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return err
+	}
+
+	_, err = revlist.Objects(repo.Storer, hashes, shallow)
+	return err
+}
+
+// verifyCopy checks the repo at copyPath matches the repo at repoPath, and if connectivity is set that no
+// objects are missing from it.
+func verifyCopy(fs billy.Filesystem, repoPath string, copyPath string, connectivity bool) error {
+	expected, err := snapshotRepo(fs, repoPath)
+	if err != nil {
+		return err
+	}
+
+	actual, err := snapshotRepo(fs, copyPath)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrVerificationFailed, err)
+	}
+
+	if differences := expected.compare(actual); len(differences) != 0 {
+		if len(differences) > maxDifferences {
+			differences = append(differences[:maxDifferences], fmt.Sprintf("and %d more", len(differences)-maxDifferences))
+		}
+
+		return fmt.Errorf("%w: %s", ErrVerificationFailed, strings.Join(differences, ", "))
+	}
+
+	if connectivity {
+		if err := checkConnectivity(fs, copyPath); err != nil {
+			return fmt.Errorf("%w: connectivity check failed: %s", ErrVerificationFailed, err)
+		}
+	}
+
+	return nil
+}
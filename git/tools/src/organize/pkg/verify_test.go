@@ -0,0 +1,120 @@
+package organize
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyCopy(t *testing.T) {
+	setup := func(t *testing.T) (string, string, *git.Repository) {
+		tempDir := t.TempDir()
+		repoDir := path.Join(tempDir, "repo")
+		copyPath := path.Join(tempDir, "copy")
+
+		repo, err := git.PlainInit(repoDir, false)
+		require.NoError(t, err)
+		commitFile(t, repo, repoDir, "README.md", "hello")
+		commitFile(t, repo, repoDir, "main.go", "package main")
+
+		head, err := repo.Head()
+		require.NoError(t, err)
+		require.NoError(t, repo.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", head.Hash())))
+
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "untracked.txt"), []byte("untracked"), 0644))
+
+		require.NoError(t, os.Symlink("README.md", path.Join(repoDir, "link")))
+
+		_, err = copyDir(context.Background(), NewOSFilesystem(), repoDir, copyPath)
+		require.NoError(t, err)
+
+		return repoDir, copyPath, repo
+	}
+
+	t.Run("OK", func(t *testing.T) {
+		repoDir, copyPath, _ := setup(t)
+
+		assert.NoError(t, verifyCopy(NewOSFilesystem(), repoDir, copyPath, true))
+	})
+
+	t.Run("ModifiedFile", func(t *testing.T) {
+		repoDir, copyPath, _ := setup(t)
+		require.NoError(t, os.WriteFile(path.Join(copyPath, "untracked.txt"), []byte("changed"), 0644))
+		require.NoError(t, os.Remove(path.Join(copyPath, "main.go")))
+
+		err := verifyCopy(NewOSFilesystem(), repoDir, copyPath, false)
+		require.ErrorIs(t, err, ErrVerificationFailed)
+		assert.ErrorContains(t, err, "file 'main.go' is missing")
+		assert.ErrorContains(t, err, "file 'untracked.txt' differs")
+	})
+
+	t.Run("ChangedSymlink", func(t *testing.T) {
+		repoDir, copyPath, _ := setup(t)
+		require.NoError(t, os.Remove(path.Join(copyPath, "link")))
+		require.NoError(t, os.Symlink("main.go", path.Join(copyPath, "link")))
+
+		err := verifyCopy(NewOSFilesystem(), repoDir, copyPath, false)
+		assert.ErrorContains(t, err, "file 'link' differs")
+	})
+
+	t.Run("MissingRef", func(t *testing.T) {
+		repoDir, copyPath, _ := setup(t)
+		require.NoError(t, os.Remove(path.Join(copyPath, ".git", "refs", "heads", "feature")))
+
+		err := verifyCopy(NewOSFilesystem(), repoDir, copyPath, false)
+		require.ErrorIs(t, err, ErrVerificationFailed)
+		assert.ErrorContains(t, err, "ref 'refs/heads/feature' is missing")
+	})
+
+	t.Run("MissingObject", func(t *testing.T) {
+		repoDir, copyPath, repo := setup(t)
+
+		head, err := repo.Head()
+		require.NoError(t, err)
+
+		hash := head.Hash().String()
+		require.NoError(t, os.Remove(path.Join(copyPath, ".git", "objects", hash[:2], hash[2:])))
+
+		err = verifyCopy(NewOSFilesystem(), repoDir, copyPath, true)
+		require.ErrorIs(t, err, ErrVerificationFailed)
+		assert.ErrorContains(t, err, "objects")
+	})
+
+	t.Run("MissingTree", func(t *testing.T) {
+		repoDir, copyPath, repo := setup(t)
+
+		head, err := repo.Head()
+		require.NoError(t, err)
+		commit, err := repo.CommitObject(head.Hash())
+		require.NoError(t, err)
+
+		// replace the tree of HEAD with an unreferenced blob so the object counts still match
+		tree := commit.TreeHash.String()
+		require.NoError(t, os.Remove(path.Join(copyPath, ".git", "objects", tree[:2], tree[2:])))
+
+		copied, err := git.PlainOpen(copyPath)
+		require.NoError(t, err)
+		blob := copied.Storer.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		w, err := blob.Writer()
+		require.NoError(t, err)
+		_, err = w.Write([]byte("unreferenced"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		_, err = copied.Storer.SetEncodedObject(blob)
+		require.NoError(t, err)
+
+		assert.NoError(t, verifyCopy(NewOSFilesystem(), repoDir, copyPath, false))
+		assert.Error(t, checkConnectivity(NewOSFilesystem(), copyPath))
+
+		err = verifyCopy(NewOSFilesystem(), repoDir, copyPath, true)
+		require.ErrorIs(t, err, ErrVerificationFailed)
+		assert.ErrorContains(t, err, "connectivity check failed")
+	})
+}
@@ -0,0 +1,104 @@
+package organize
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+// resolveGitDir returns the path to the git directory for the repository at repoPath, following any
+// "gitdir:" files.
+func resolveGitDir(fs billy.Filesystem, repoPath string) (string, error) {
+	dotGit := path.Join(repoPath, ".git")
+
+	info, err := fs.Stat(dotGit)
+	if err != nil {
+		return "", err
+	}
+
+	if info.IsDir() {
+		return dotGit, nil
+	}
+
+	gitDir, _, err := readGitdirFile(fs, dotGit, repoPath)
+	return gitDir, err
+}
+
+// findGitDir returns the git directory of the repository at repoPath, which is repoPath itself for bare
+// repositories. An error satisfying os.IsNotExist is returned if repoPath does not look like a repository.
+func findGitDir(fs billy.Filesystem, repoPath string) (string, error) {
+	gitDir, err := resolveGitDir(fs, repoPath)
+	if !os.IsNotExist(err) {
+		return gitDir, err
+	}
+
+	for _, name := range []string{"HEAD", "objects"} {
+		if _, err := fs.Stat(path.Join(repoPath, name)); err != nil {
+			return "", err
+		}
+	}
+
+	return repoPath, nil
+}
+
+// IsRepo returns true if repoPath has a git directory, or is a bare repository.
+func IsRepo(fs billy.Filesystem, repoPath string) (bool, error) {
+	_, err := findGitDir(fs, repoPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// isTransientGitFile returns true if name is a file git only keeps around while it is still writing to a
+// repository.
+func isTransientGitFile(name string) bool {
+	return strings.HasSuffix(name, ".lock") || strings.HasPrefix(name, "tmp_pack_") || strings.HasPrefix(name, "tmp_idx_")
+}
+
+// IsRepoSettled returns true if the repository at repoPath looks like a complete clone: it has a git
+// directory with a HEAD, or is a bare repository, and no lock or temporary pack files are present.
+func IsRepoSettled(fs billy.Filesystem, repoPath string) (bool, error) {
+	gitDir, err := findGitDir(fs, repoPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if _, err := fs.Stat(path.Join(gitDir, "HEAD")); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	settled := true
+	err = util.Walk(fs, gitDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			// files may disappear while git is still working
+			if os.IsNotExist(err) {
+				settled = false
+				return nil
+			}
+			return err
+		}
+
+		if isTransientGitFile(info.Name()) {
+			settled = false
+			return filepath.SkipAll
+		}
+
+		return nil
+	})
+	if err != nil && err != filepath.SkipAll {
+		return false, fmt.Errorf("could not check repo '%s' for lock files: %w", repoPath, err)
+	}
+
+	return settled, nil
+}
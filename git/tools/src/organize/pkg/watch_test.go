@@ -0,0 +1,70 @@
+package organize
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRepoSettled(t *testing.T) {
+	t.Run("NotARepo", func(t *testing.T) {
+		dir := t.TempDir()
+
+		isRepo, err := IsRepo(NewOSFilesystem(), dir)
+		require.NoError(t, err)
+		assert.False(t, isRepo)
+
+		settled, err := IsRepoSettled(NewOSFilesystem(), dir)
+		require.NoError(t, err)
+		assert.False(t, settled)
+	})
+
+	t.Run("Bare", func(t *testing.T) {
+		repoDir, _ := BareRepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+
+		isRepo, err := IsRepo(NewOSFilesystem(), repoDir)
+		require.NoError(t, err)
+		assert.True(t, isRepo)
+
+		settled, err := IsRepoSettled(NewOSFilesystem(), repoDir)
+		require.NoError(t, err)
+		assert.True(t, settled)
+
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "HEAD.lock"), nil, 0644))
+
+		settled, err = IsRepoSettled(NewOSFilesystem(), repoDir)
+		require.NoError(t, err)
+		assert.False(t, settled)
+	})
+
+	t.Run("Settled", func(t *testing.T) {
+		repoDir, _ := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+
+		settled, err := IsRepoSettled(NewOSFilesystem(), repoDir)
+		require.NoError(t, err)
+		assert.True(t, settled)
+	})
+
+	t.Run("LockFile", func(t *testing.T) {
+		repoDir, _ := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "storage", "index.lock"), nil, 0644))
+
+		settled, err := IsRepoSettled(NewOSFilesystem(), repoDir)
+		require.NoError(t, err)
+		assert.False(t, settled)
+	})
+
+	t.Run("TempPack", func(t *testing.T) {
+		repoDir, _ := RepoWithRemotes(t, t.TempDir(), []*config.RemoteConfig{remoteOrigin})
+		require.NoError(t, os.MkdirAll(path.Join(repoDir, "storage", "objects", "pack"), 0755))
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "storage", "objects", "pack", "tmp_pack_abc123"), nil, 0644))
+
+		settled, err := IsRepoSettled(NewOSFilesystem(), repoDir)
+		require.NoError(t, err)
+		assert.False(t, settled)
+	})
+}
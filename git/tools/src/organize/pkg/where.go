@@ -0,0 +1,652 @@
+package organize
+
+//go:generate goyacc -l -p where -o where_parser.go -v where.output where.y
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-git/v5"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// ErrInvalidFilter is returned when a where expression cannot be parsed or is not well typed.
+var ErrInvalidFilter = errors.New("invalid where expression")
+
+func init() {
+	whereErrorVerbose = true
+}
+
+// whereKind is the type of value a where expression evaluates to.
+type whereKind int
+
+const (
+	whereString whereKind = iota
+	whereNumber
+	whereBool
+)
+
+func (kind whereKind) String() string {
+	switch kind {
+	case whereString:
+		return "string"
+	case whereNumber:
+		return "number"
+	default:
+		return "boolean"
+	}
+}
+
+type whereValue struct {
+	kind whereKind
+	str  string
+	num  int
+	b    bool
+}
+
+func boolValue(b bool) whereValue {
+	return whereValue{kind: whereBool, b: b}
+}
+
+func (value whereValue) String() string {
+	switch value.kind {
+	case whereString:
+		return strconv.Quote(value.str)
+	case whereNumber:
+		return strconv.Itoa(value.num)
+	default:
+		return strconv.FormatBool(value.b)
+	}
+}
+
+// whereExpr is a node of a parsed where expression.
+type whereExpr interface {
+	// check returns the kind of value the expression evaluates to, or an error if it is not well typed.
+	check() (whereKind, error)
+
+	eval(env *whereEnv) (whereValue, error)
+
+	String() string
+}
+
+type whereLiteral struct {
+	value whereValue
+}
+
+func (expr *whereLiteral) check() (whereKind, error) {
+	return expr.value.kind, nil
+}
+
+func (expr *whereLiteral) eval(*whereEnv) (whereValue, error) {
+	return expr.value, nil
+}
+
+func (expr *whereLiteral) String() string {
+	return expr.value.String()
+}
+
+type whereField struct {
+	name string
+}
+
+func (expr *whereField) check() (whereKind, error) {
+	field, found := whereFields[expr.name]
+	if !found {
+		names := maps.Keys(whereFields)
+		slices.Sort(names)
+
+		return 0, fmt.Errorf("unknown field '%s', expected one of: %s", expr.name, strings.Join(names, ", "))
+	}
+
+	return field.kind, nil
+}
+
+func (expr *whereField) eval(env *whereEnv) (whereValue, error) {
+	return env.field(expr.name)
+}
+
+func (expr *whereField) String() string {
+	return expr.name
+}
+
+type whereNot struct {
+	expr whereExpr
+}
+
+func (expr *whereNot) check() (whereKind, error) {
+	kind, err := expr.expr.check()
+	if err != nil {
+		return 0, err
+	}
+
+	if kind != whereBool {
+		return 0, fmt.Errorf("'!' expects a boolean, but %s is a %s", expr.expr, kind)
+	}
+
+	return whereBool, nil
+}
+
+func (expr *whereNot) eval(env *whereEnv) (whereValue, error) {
+	value, err := expr.expr.eval(env)
+	if err != nil {
+		return value, err
+	}
+
+	return boolValue(!value.b), nil
+}
+
+func (expr *whereNot) String() string {
+	return "!" + expr.expr.String()
+}
+
+type whereLogical struct {
+	op    string
+	left  whereExpr
+	right whereExpr
+}
+
+func (expr *whereLogical) check() (whereKind, error) {
+	for _, operand := range []whereExpr{expr.left, expr.right} {
+		kind, err := operand.check()
+		if err != nil {
+			return 0, err
+		}
+
+		if kind != whereBool {
+			return 0, fmt.Errorf("'%s' expects booleans, but %s is a %s", expr.op, operand, kind)
+		}
+	}
+
+	return whereBool, nil
+}
+
+// eval only evaluates the right side if it can change the result, so fields on the right which are expensive
+// to determine are skipped where possible.
+func (expr *whereLogical) eval(env *whereEnv) (whereValue, error) {
+	left, err := expr.left.eval(env)
+	if err != nil {
+		return left, err
+	}
+
+	if left.b == (expr.op == "||") {
+		return left, nil
+	}
+
+	return expr.right.eval(env)
+}
+
+func (expr *whereLogical) String() string {
+	return fmt.Sprintf("(%s %s %s)", expr.left, expr.op, expr.right)
+}
+
+type whereCompare struct {
+	op    string
+	left  whereExpr
+	right whereExpr
+
+	// re is the compiled right side of '=~' and '!~'
+	re *regexp.Regexp
+}
+
+func (expr *whereCompare) check() (whereKind, error) {
+	left, err := expr.left.check()
+	if err != nil {
+		return 0, err
+	}
+
+	right, err := expr.right.check()
+	if err != nil {
+		return 0, err
+	}
+
+	if expr.op == "=~" || expr.op == "!~" {
+		literal, ok := expr.right.(*whereLiteral)
+		if left != whereString || !ok || right != whereString {
+			return 0, fmt.Errorf("'%s' expects a string on the left and a regular expression string literal on the right, not %s and %s", expr.op, expr.left, expr.right)
+		}
+
+		if expr.re, err = regexp.Compile(literal.value.str); err != nil {
+			return 0, fmt.Errorf("bad regular expression %s: %w", literal, err)
+		}
+
+		return whereBool, nil
+	}
+
+	if left != right {
+		return 0, fmt.Errorf("cannot compare %s (%s) with %s (%s)", expr.left, left, expr.right, right)
+	}
+
+	if left == whereBool && expr.op != "==" && expr.op != "!=" {
+		return 0, fmt.Errorf("'%s' cannot be used with booleans", expr.op)
+	}
+
+	return whereBool, nil
+}
+
+func (expr *whereCompare) eval(env *whereEnv) (whereValue, error) {
+	left, err := expr.left.eval(env)
+	if err != nil {
+		return left, err
+	}
+
+	right, err := expr.right.eval(env)
+	if err != nil {
+		return right, err
+	}
+
+	var order int
+	if left.kind == whereString {
+		order = strings.Compare(left.str, right.str)
+	} else if left.num < right.num {
+		order = -1
+	} else if left.num > right.num {
+		order = 1
+	}
+
+	switch expr.op {
+	case "=~":
+		return boolValue(expr.re.MatchString(left.str)), nil
+	case "!~":
+		return boolValue(!expr.re.MatchString(left.str)), nil
+	case "==":
+		return boolValue(left == right), nil
+	case "!=":
+		return boolValue(left != right), nil
+	case "<":
+		return boolValue(order < 0), nil
+	case "<=":
+		return boolValue(order <= 0), nil
+	case ">":
+		return boolValue(order > 0), nil
+	case ">=":
+		return boolValue(order >= 0), nil
+	default:
+		return whereValue{}, fmt.Errorf("unsupported operator '%s'", expr.op)
+	}
+}
+
+func (expr *whereCompare) String() string {
+	return fmt.Sprintf("%s %s %s", expr.left, expr.op, expr.right)
+}
+
+// whereEnv provides the values of fields for a single repo. Each field is only determined the first time it
+// is used.
+type whereEnv struct {
+	fs       billy.Filesystem
+	config   Config
+	repoPath string
+	repo     *git.Repository
+	aliases  remoteAliases
+	values   map[string]whereValue
+}
+
+func (env *whereEnv) field(name string) (whereValue, error) {
+	if value, found := env.values[name]; found {
+		return value, nil
+	}
+
+	value, err := whereFields[name].value(env)
+	if err != nil {
+		return value, fmt.Errorf("could not determine %s of '%s': %w", name, env.repoPath, err)
+	}
+
+	env.values[name] = value
+
+	return value, nil
+}
+
+// remote returns the primary remote, or the first remote by name if there is no primary remote, or nil if
+// there are no remotes at all.
+func (env *whereEnv) remote() (*git.Remote, error) {
+	remotes, err := resolvedRemotes(env.config, env.aliases, env.repo)
+	if err != nil || len(remotes) == 0 {
+		return nil, err
+	}
+
+	for _, remote := range remotes {
+		if remote.Config().Name == env.config.primaryRemote() {
+			return remote, nil
+		}
+	}
+
+	slices.SortFunc(remotes, func(a, b *git.Remote) bool {
+		return a.Config().Name < b.Config().Name
+	})
+
+	return remotes[0], nil
+}
+
+// remoteString returns a value derived from the remote, or an empty string if there is no remote or the
+// value cannot be derived from its url.
+func remoteString(fn func(remote *git.Remote) (string, error)) func(env *whereEnv) (whereValue, error) {
+	return func(env *whereEnv) (whereValue, error) {
+		remote, err := env.remote()
+		if err != nil || remote == nil {
+			return whereValue{kind: whereString}, err
+		}
+
+		s, _ := fn(remote)
+
+		return whereValue{kind: whereString, str: s}, nil
+	}
+}
+
+// whereFieldDef describes a field which can be used in where expressions.
+type whereFieldDef struct {
+	kind  whereKind
+	value func(env *whereEnv) (whereValue, error)
+}
+
+var whereFields = map[string]whereFieldDef{
+	"path": {kind: whereString, value: func(env *whereEnv) (whereValue, error) {
+		return whereValue{kind: whereString, str: absPath(env.fs, env.repoPath)}, nil
+	}},
+	"host": {kind: whereString, value: remoteString(getRemoteHost)},
+	"owner": {kind: whereString, value: remoteString(func(remote *git.Remote) (string, error) {
+		owner, _, err := getRemoteOwnerAndName(remote)
+		return owner, err
+	})},
+	"name": {kind: whereString, value: remoteString(func(remote *git.Remote) (string, error) {
+		_, name, err := getRemoteOwnerAndName(remote)
+		return name, err
+	})},
+	"url": {kind: whereString, value: remoteString(func(remote *git.Remote) (string, error) {
+		if len(remote.Config().URLs) == 0 {
+			return "", nil
+		}
+		return remote.Config().URLs[0], nil
+	})},
+	"remotes": {kind: whereNumber, value: func(env *whereEnv) (whereValue, error) {
+		remotes, err := env.repo.Remotes()
+		return whereValue{kind: whereNumber, num: len(remotes)}, err
+	}},
+	"branch": {kind: whereString, value: func(env *whereEnv) (whereValue, error) {
+		value := whereValue{kind: whereString}
+		if head, err := env.repo.Head(); err == nil && head.Name().IsBranch() {
+			value.str = head.Name().Short()
+		}
+		return value, nil
+	}},
+	"bare": {kind: whereBool, value: func(env *whereEnv) (whereValue, error) {
+		_, err := env.repo.Worktree()
+		if errors.Is(err, git.ErrIsBareRepository) {
+			return boolValue(true), nil
+		}
+		return boolValue(false), err
+	}},
+	"dirty": {kind: whereBool, value: func(env *whereEnv) (whereValue, error) {
+		worktree, err := env.repo.Worktree()
+		if errors.Is(err, git.ErrIsBareRepository) {
+			return boolValue(false), nil
+		} else if err != nil {
+			return boolValue(false), err
+		}
+
+		status, err := worktree.Status()
+		if err != nil {
+			return boolValue(false), err
+		}
+
+		return boolValue(!status.IsClean()), nil
+	}},
+}
+
+// whereTokens are friendlier names for the tokens in syntax errors.
+var whereTokens = map[string]string{
+	"$end":       "end of expression",
+	"tokIdent":   "field",
+	"tokString":  "string",
+	"tokNumber":  "number",
+	"tokBool":    "boolean",
+	"tokOr":      "'||'",
+	"tokAnd":     "'&&'",
+	"tokEq":      "'=='",
+	"tokNe":      "'!='",
+	"tokMatch":   "'=~'",
+	"tokNoMatch": "'!~'",
+	"tokLt":      "'<'",
+	"tokLe":      "'<='",
+	"tokGt":      "'>'",
+	"tokGe":      "'>='",
+}
+
+var whereTokenPattern = regexp.MustCompile(`\$end|\btok[A-Za-z]+`)
+
+// whereOperators are the operators made of more than a single character, or whose token is not the character
+// itself.
+var whereOperators = []struct {
+	text  string
+	token int
+}{
+	{"||", tokOr},
+	{"&&", tokAnd},
+	{"==", tokEq},
+	{"!=", tokNe},
+	{"=~", tokMatch},
+	{"!~", tokNoMatch},
+	{"<=", tokLe},
+	{">=", tokGe},
+	{"<", tokLt},
+	{">", tokGt},
+}
+
+// whereScanner splits a where expression into tokens for whereParse.
+type whereScanner struct {
+	input string
+	pos   int
+
+	// start is where the last token started, which is where any syntax error is reported
+	start int
+
+	expr whereExpr
+	err  error
+}
+
+func (scanner *whereScanner) fail(format string, args ...any) int {
+	if scanner.err == nil {
+		scanner.err = fmt.Errorf("%s at column %d", fmt.Sprintf(format, args...), scanner.start+1)
+	}
+
+	return 0
+}
+
+func (scanner *whereScanner) Error(msg string) {
+	msg = whereTokenPattern.ReplaceAllStringFunc(msg, func(token string) string {
+		return whereTokens[token]
+	})
+
+	if scanner.err != nil {
+		return
+	}
+
+	rest, _ := strings.CutPrefix(msg, "syntax error: ")
+
+	if near := scanner.input[scanner.start:scanner.pos]; near != "" {
+		scanner.err = fmt.Errorf("syntax error at column %d near '%s': %s", scanner.start+1, near, rest)
+	} else {
+		scanner.err = fmt.Errorf("syntax error at column %d: %s", scanner.start+1, rest)
+	}
+}
+
+func (scanner *whereScanner) Lex(lval *whereSymType) int {
+	for scanner.pos < len(scanner.input) {
+		r, size := utf8.DecodeRuneInString(scanner.input[scanner.pos:])
+		if !unicode.IsSpace(r) {
+			break
+		}
+		scanner.pos += size
+	}
+
+	scanner.start = scanner.pos
+	if scanner.pos == len(scanner.input) {
+		return 0
+	}
+
+	rest := scanner.input[scanner.pos:]
+	r, _ := utf8.DecodeRuneInString(rest)
+
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if end == -1 {
+			end = len(rest)
+		}
+
+		scanner.pos += end
+
+		switch word := rest[:end]; word {
+		case "true", "false":
+			lval.Bool = word == "true"
+			return tokBool
+		default:
+			lval.String = word
+			return tokIdent
+		}
+	case unicode.IsDigit(r):
+		end := strings.IndexFunc(rest, func(r rune) bool {
+			return !unicode.IsDigit(r)
+		})
+		if end == -1 {
+			end = len(rest)
+		}
+
+		scanner.pos += end
+
+		n, err := strconv.Atoi(rest[:end])
+		if err != nil {
+			return scanner.fail("bad number '%s'", rest[:end])
+		}
+
+		lval.Number = n
+		return tokNumber
+	case r == '"' || r == '`':
+		end := 1
+		for ; end < len(rest) && rest[end] != byte(r); end++ {
+			if r == '"' && rest[end] == '\\' {
+				end++
+			}
+		}
+
+		if end >= len(rest) {
+			return scanner.fail("unterminated string")
+		}
+
+		scanner.pos += end + 1
+
+		s, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return scanner.fail("bad string %s", rest[:end+1])
+		}
+
+		lval.String = s
+		return tokString
+	}
+
+	for _, op := range whereOperators {
+		if strings.HasPrefix(rest, op.text) {
+			scanner.pos += len(op.text)
+			lval.String = op.text
+			return op.token
+		}
+	}
+
+	switch r {
+	case '!', '(', ')':
+		scanner.pos++
+		return int(r)
+	case '=':
+		return scanner.fail("unexpected '=', use '==' to compare values")
+	default:
+		return scanner.fail("unexpected character %q", r)
+	}
+}
+
+func setWhereResult(lexer whereLexer, expr whereExpr) {
+	lexer.(*whereScanner).expr = expr
+}
+
+// Filter selects repos using a where expression such as `host == "github.com" && !dirty && remotes > 1`.
+//
+// Expressions compare fields of a repo with string, number, or boolean literals using '==', '!=', '<', '<=',
+// '>', and '>=', and strings with regular expressions using '=~' and '!~'. Comparisons are combined with
+// '&&', '||', '!', and parentheses. Strings are double quoted with Go escapes, or backquoted to avoid
+// escaping regular expressions. The fields are:
+//
+//   - path: the absolute path to the repo
+//   - host, owner, name, url: the host, owner, name, and first url of the primary remote, or of the first
+//     remote by name if there is no primary remote, with insteadOf and ssh Host aliases resolved
+//   - remotes: how many remotes the repo has
+//   - branch: the branch checked out, or empty if HEAD is detached
+//   - bare: whether the repo is bare
+//   - dirty: whether the worktree has changes, which is never true for bare repos
+type Filter struct {
+	source string
+	expr   whereExpr
+}
+
+// ParseFilter parses a where expression.
+func ParseFilter(s string) (*Filter, error) {
+	scanner := &whereScanner{input: s}
+
+	if whereParse(scanner) != 0 || scanner.err != nil {
+		if scanner.err == nil {
+			scanner.err = errors.New("syntax error")
+		}
+
+		return nil, fmt.Errorf("%w '%s': %s", ErrInvalidFilter, s, scanner.err)
+	}
+
+	kind, err := scanner.expr.check()
+	if err != nil {
+		return nil, fmt.Errorf("%w '%s': %s", ErrInvalidFilter, s, err)
+	}
+
+	if kind != whereBool {
+		return nil, fmt.Errorf("%w '%s': expected a boolean, but %s is a %s", ErrInvalidFilter, s, scanner.expr, kind)
+	}
+
+	return &Filter{source: s, expr: scanner.expr}, nil
+}
+
+// String returns the expression the filter was parsed from.
+func (filter *Filter) String() string {
+	return filter.source
+}
+
+// Match returns true if the repo at repoPath in the OS filesystem matches filter. Fields are only determined
+// when they are needed, so fields which are expensive to determine, such as dirty, are skipped where possible.
+func (filter *Filter) Match(config Config, repoPath string, repo *git.Repository) (bool, error) {
+	// as with an Organizer's default logger, aliases which can not be loaded are silently ignored
+	fs := NewOSFilesystem()
+	aliases, _ := loadUserAliases(fs)
+
+	return filter.match(fs, config, aliases, repoPath, repo)
+}
+
+// match is Match for a repo in fs, with its remote urls resolved using aliases.
+func (filter *Filter) match(fs billy.Filesystem, config Config, aliases remoteAliases, repoPath string, repo *git.Repository) (bool, error) {
+	env := &whereEnv{
+		fs:       fs,
+		config:   config,
+		repoPath: repoPath,
+		repo:     repo,
+		aliases:  aliases,
+		values:   make(map[string]whereValue),
+	}
+
+	value, err := filter.expr.eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	return value.b, nil
+}
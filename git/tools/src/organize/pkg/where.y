@@ -0,0 +1,55 @@
+%{
+package organize
+%}
+
+%union {
+	String string
+	Number int
+	Bool bool
+	Expr whereExpr
+}
+
+%token<String> tokIdent tokString
+%token<Number> tokNumber
+%token<Bool> tokBool
+%token<String> tokOr tokAnd tokEq tokNe tokMatch tokNoMatch tokLt tokLe tokGt tokGe
+
+%type<Expr> expr or and unary comparison operand
+%type<String> operator
+
+%%
+
+entry: expr
+{
+	setWhereResult(wherelex, $1)
+}
+
+expr: or
+	;
+
+or: and
+	| or tokOr and { $$ = &whereLogical{op: $2, left: $1, right: $3} }
+	;
+
+and: unary
+	| and tokAnd unary { $$ = &whereLogical{op: $2, left: $1, right: $3} }
+	;
+
+unary: comparison
+	| '!' unary { $$ = &whereNot{expr: $2} }
+	;
+
+comparison: operand
+	| operand operator operand { $$ = &whereCompare{op: $2, left: $1, right: $3} }
+	;
+
+operator: tokEq | tokNe | tokMatch | tokNoMatch | tokLt | tokLe | tokGt | tokGe
+	;
+
+operand: tokIdent { $$ = &whereField{name: $1} }
+	| tokString { $$ = &whereLiteral{value: whereValue{kind: whereString, str: $1}} }
+	| tokNumber { $$ = &whereLiteral{value: whereValue{kind: whereNumber, num: $1}} }
+	| tokBool { $$ = &whereLiteral{value: whereValue{kind: whereBool, b: $1}} }
+	| '(' expr ')' { $$ = $2 }
+	;
+%%
@@ -0,0 +1,516 @@
+// Code generated by goyacc -l -p where -o where_parser.go -v where.output where.y. DO NOT EDIT.
+package organize
+
+import __yyfmt__ "fmt"
+
+type whereSymType struct {
+	yys    int
+	String string
+	Number int
+	Bool   bool
+	Expr   whereExpr
+}
+
+const tokIdent = 57346
+const tokString = 57347
+const tokNumber = 57348
+const tokBool = 57349
+const tokOr = 57350
+const tokAnd = 57351
+const tokEq = 57352
+const tokNe = 57353
+const tokMatch = 57354
+const tokNoMatch = 57355
+const tokLt = 57356
+const tokLe = 57357
+const tokGt = 57358
+const tokGe = 57359
+
+var whereToknames = [...]string{
+	"$end",
+	"error",
+	"$unk",
+	"tokIdent",
+	"tokString",
+	"tokNumber",
+	"tokBool",
+	"tokOr",
+	"tokAnd",
+	"tokEq",
+	"tokNe",
+	"tokMatch",
+	"tokNoMatch",
+	"tokLt",
+	"tokLe",
+	"tokGt",
+	"tokGe",
+	"'!'",
+	"'('",
+	"')'",
+}
+
+var whereStatenames = [...]string{}
+
+const whereEofCode = 1
+const whereErrCode = 2
+const whereInitialStackSize = 16
+
+var whereExca = [...]int8{
+	-1, 1,
+	1, -1,
+	-2, 0,
+}
+
+const wherePrivate = 57344
+
+const whereLast = 40
+
+var whereAct = [...]int8{
+	9, 10, 11, 12, 18, 19, 20, 21, 22, 23,
+	24, 25, 30, 5, 7, 13, 9, 10, 11, 12,
+	8, 16, 4, 15, 14, 2, 1, 17, 6, 28,
+	3, 13, 0, 0, 0, 0, 0, 27, 29, 26,
+}
+
+var wherePact = [...]int16{
+	-4, -1000, -1000, 16, 14, -1000, -1000, -4, -6, -1000,
+	-1000, -1000, -1000, -4, -4, -4, -1000, 12, -1000, -1000,
+	-1000, -1000, -1000, -1000, -1000, -1000, -8, 14, -1000, -1000,
+	-1000,
+}
+
+var wherePgo = [...]int8{
+	0, 25, 30, 22, 13, 28, 20, 27, 26,
+}
+
+var whereR1 = [...]int8{
+	0, 8, 1, 2, 2, 3, 3, 4, 4, 5,
+	5, 7, 7, 7, 7, 7, 7, 7, 7, 6,
+	6, 6, 6, 6,
+}
+
+var whereR2 = [...]int8{
+	0, 1, 1, 1, 3, 1, 3, 1, 2, 1,
+	3, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+	1, 1, 1, 3,
+}
+
+var whereChk = [...]int16{
+	-1000, -8, -1, -2, -3, -4, -5, 18, -6, 4,
+	5, 6, 7, 19, 8, 9, -4, -7, 10, 11,
+	12, 13, 14, 15, 16, 17, -1, -3, -4, -6,
+	20,
+}
+
+var whereDef = [...]int8{
+	0, -2, 1, 2, 3, 5, 7, 0, 9, 19,
+	20, 21, 22, 0, 0, 0, 8, 0, 11, 12,
+	13, 14, 15, 16, 17, 18, 0, 4, 6, 10,
+	23,
+}
+
+var whereTok1 = [...]int8{
+	1, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 3, 3, 3, 3, 3, 3, 3,
+	3, 3, 3, 18, 3, 3, 3, 3, 3, 3,
+	19, 20,
+}
+
+var whereTok2 = [...]int8{
+	2, 3, 4, 5, 6, 7, 8, 9, 10, 11,
+	12, 13, 14, 15, 16, 17,
+}
+
+var whereTok3 = [...]int8{
+	0,
+}
+
+var whereErrorMessages = [...]struct {
+	state int
+	token int
+	msg   string
+}{}
+
+/*	parser for yacc output	*/
+
+var (
+	whereDebug        = 0
+	whereErrorVerbose = false
+)
+
+type whereLexer interface {
+	Lex(lval *whereSymType) int
+	Error(s string)
+}
+
+type whereParser interface {
+	Parse(whereLexer) int
+	Lookahead() int
+}
+
+type whereParserImpl struct {
+	lval  whereSymType
+	stack [whereInitialStackSize]whereSymType
+	char  int
+}
+
+func (p *whereParserImpl) Lookahead() int {
+	return p.char
+}
+
+func whereNewParser() whereParser {
+	return &whereParserImpl{}
+}
+
+const whereFlag = -1000
+
+func whereTokname(c int) string {
+	if c >= 1 && c-1 < len(whereToknames) {
+		if whereToknames[c-1] != "" {
+			return whereToknames[c-1]
+		}
+	}
+	return __yyfmt__.Sprintf("tok-%v", c)
+}
+
+func whereStatname(s int) string {
+	if s >= 0 && s < len(whereStatenames) {
+		if whereStatenames[s] != "" {
+			return whereStatenames[s]
+		}
+	}
+	return __yyfmt__.Sprintf("state-%v", s)
+}
+
+func whereErrorMessage(state, lookAhead int) string {
+	const TOKSTART = 4
+
+	if !whereErrorVerbose {
+		return "syntax error"
+	}
+
+	for _, e := range whereErrorMessages {
+		if e.state == state && e.token == lookAhead {
+			return "syntax error: " + e.msg
+		}
+	}
+
+	res := "syntax error: unexpected " + whereTokname(lookAhead)
+
+	// To match Bison, suggest at most four expected tokens.
+	expected := make([]int, 0, 4)
+
+	// Look for shiftable tokens.
+	base := int(wherePact[state])
+	for tok := TOKSTART; tok-1 < len(whereToknames); tok++ {
+		if n := base + tok; n >= 0 && n < whereLast && int(whereChk[int(whereAct[n])]) == tok {
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+	}
+
+	if whereDef[state] == -2 {
+		i := 0
+		for whereExca[i] != -1 || int(whereExca[i+1]) != state {
+			i += 2
+		}
+
+		// Look for tokens that we accept or reduce.
+		for i += 2; whereExca[i] >= 0; i += 2 {
+			tok := int(whereExca[i])
+			if tok < TOKSTART || whereExca[i+1] == 0 {
+				continue
+			}
+			if len(expected) == cap(expected) {
+				return res
+			}
+			expected = append(expected, tok)
+		}
+
+		// If the default action is to accept or reduce, give up.
+		if whereExca[i+1] != 0 {
+			return res
+		}
+	}
+
+	for i, tok := range expected {
+		if i == 0 {
+			res += ", expecting "
+		} else {
+			res += " or "
+		}
+		res += whereTokname(tok)
+	}
+	return res
+}
+
+func wherelex1(lex whereLexer, lval *whereSymType) (char, token int) {
+	token = 0
+	char = lex.Lex(lval)
+	if char <= 0 {
+		token = int(whereTok1[0])
+		goto out
+	}
+	if char < len(whereTok1) {
+		token = int(whereTok1[char])
+		goto out
+	}
+	if char >= wherePrivate {
+		if char < wherePrivate+len(whereTok2) {
+			token = int(whereTok2[char-wherePrivate])
+			goto out
+		}
+	}
+	for i := 0; i < len(whereTok3); i += 2 {
+		token = int(whereTok3[i+0])
+		if token == char {
+			token = int(whereTok3[i+1])
+			goto out
+		}
+	}
+
+out:
+	if token == 0 {
+		token = int(whereTok2[1]) /* unknown char */
+	}
+	if whereDebug >= 3 {
+		__yyfmt__.Printf("lex %s(%d)\n", whereTokname(token), uint(char))
+	}
+	return char, token
+}
+
+func whereParse(wherelex whereLexer) int {
+	return whereNewParser().Parse(wherelex)
+}
+
+func (wherercvr *whereParserImpl) Parse(wherelex whereLexer) int {
+	var wheren int
+	var whereVAL whereSymType
+	var whereDollar []whereSymType
+	_ = whereDollar // silence set and not used
+	whereS := wherercvr.stack[:]
+
+	Nerrs := 0   /* number of errors */
+	Errflag := 0 /* error recovery flag */
+	wherestate := 0
+	wherercvr.char = -1
+	wheretoken := -1 // wherercvr.char translated into internal numbering
+	defer func() {
+		// Make sure we report no lookahead when not parsing.
+		wherestate = -1
+		wherercvr.char = -1
+		wheretoken = -1
+	}()
+	wherep := -1
+	goto wherestack
+
+ret0:
+	return 0
+
+ret1:
+	return 1
+
+wherestack:
+	/* put a state and value onto the stack */
+	if whereDebug >= 4 {
+		__yyfmt__.Printf("char %v in %v\n", whereTokname(wheretoken), whereStatname(wherestate))
+	}
+
+	wherep++
+	if wherep >= len(whereS) {
+		nyys := make([]whereSymType, len(whereS)*2)
+		copy(nyys, whereS)
+		whereS = nyys
+	}
+	whereS[wherep] = whereVAL
+	whereS[wherep].yys = wherestate
+
+wherenewstate:
+	wheren = int(wherePact[wherestate])
+	if wheren <= whereFlag {
+		goto wheredefault /* simple state */
+	}
+	if wherercvr.char < 0 {
+		wherercvr.char, wheretoken = wherelex1(wherelex, &wherercvr.lval)
+	}
+	wheren += wheretoken
+	if wheren < 0 || wheren >= whereLast {
+		goto wheredefault
+	}
+	wheren = int(whereAct[wheren])
+	if int(whereChk[wheren]) == wheretoken { /* valid shift */
+		wherercvr.char = -1
+		wheretoken = -1
+		whereVAL = wherercvr.lval
+		wherestate = wheren
+		if Errflag > 0 {
+			Errflag--
+		}
+		goto wherestack
+	}
+
+wheredefault:
+	/* default state action */
+	wheren = int(whereDef[wherestate])
+	if wheren == -2 {
+		if wherercvr.char < 0 {
+			wherercvr.char, wheretoken = wherelex1(wherelex, &wherercvr.lval)
+		}
+
+		/* look through exception table */
+		xi := 0
+		for {
+			if whereExca[xi+0] == -1 && int(whereExca[xi+1]) == wherestate {
+				break
+			}
+			xi += 2
+		}
+		for xi += 2; ; xi += 2 {
+			wheren = int(whereExca[xi+0])
+			if wheren < 0 || wheren == wheretoken {
+				break
+			}
+		}
+		wheren = int(whereExca[xi+1])
+		if wheren < 0 {
+			goto ret0
+		}
+	}
+	if wheren == 0 {
+		/* error ... attempt to resume parsing */
+		switch Errflag {
+		case 0: /* brand new error */
+			wherelex.Error(whereErrorMessage(wherestate, wheretoken))
+			Nerrs++
+			if whereDebug >= 1 {
+				__yyfmt__.Printf("%s", whereStatname(wherestate))
+				__yyfmt__.Printf(" saw %s\n", whereTokname(wheretoken))
+			}
+			fallthrough
+
+		case 1, 2: /* incompletely recovered error ... try again */
+			Errflag = 3
+
+			/* find a state where "error" is a legal shift action */
+			for wherep >= 0 {
+				wheren = int(wherePact[whereS[wherep].yys]) + whereErrCode
+				if wheren >= 0 && wheren < whereLast {
+					wherestate = int(whereAct[wheren]) /* simulate a shift of "error" */
+					if int(whereChk[wherestate]) == whereErrCode {
+						goto wherestack
+					}
+				}
+
+				/* the current p has no shift on "error", pop stack */
+				if whereDebug >= 2 {
+					__yyfmt__.Printf("error recovery pops state %d\n", whereS[wherep].yys)
+				}
+				wherep--
+			}
+			/* there is no state on the stack with an error shift ... abort */
+			goto ret1
+
+		case 3: /* no shift yet; clobber input char */
+			if whereDebug >= 2 {
+				__yyfmt__.Printf("error recovery discards %s\n", whereTokname(wheretoken))
+			}
+			if wheretoken == whereEofCode {
+				goto ret1
+			}
+			wherercvr.char = -1
+			wheretoken = -1
+			goto wherenewstate /* try again in the same state */
+		}
+	}
+
+	/* reduction by production wheren */
+	if whereDebug >= 2 {
+		__yyfmt__.Printf("reduce %v in:\n\t%v\n", wheren, whereStatname(wherestate))
+	}
+
+	wherent := wheren
+	wherept := wherep
+	_ = wherept // guard against "declared and not used"
+
+	wherep -= int(whereR2[wheren])
+	// wherep is now the index of $0. Perform the default action. Iff the
+	// reduced production is ε, $1 is possibly out of range.
+	if wherep+1 >= len(whereS) {
+		nyys := make([]whereSymType, len(whereS)*2)
+		copy(nyys, whereS)
+		whereS = nyys
+	}
+	whereVAL = whereS[wherep+1]
+
+	/* consult goto table to find next state */
+	wheren = int(whereR1[wheren])
+	whereg := int(wherePgo[wheren])
+	wherej := whereg + whereS[wherep].yys + 1
+
+	if wherej >= whereLast {
+		wherestate = int(whereAct[whereg])
+	} else {
+		wherestate = int(whereAct[wherej])
+		if int(whereChk[wherestate]) != -wheren {
+			wherestate = int(whereAct[whereg])
+		}
+	}
+	// dummy call; replaced with literal code
+	switch wherent {
+
+	case 1:
+		whereDollar = whereS[wherept-1 : wherept+1]
+		{
+			setWhereResult(wherelex, whereDollar[1].Expr)
+		}
+	case 4:
+		whereDollar = whereS[wherept-3 : wherept+1]
+		{
+			whereVAL.Expr = &whereLogical{op: whereDollar[2].String, left: whereDollar[1].Expr, right: whereDollar[3].Expr}
+		}
+	case 6:
+		whereDollar = whereS[wherept-3 : wherept+1]
+		{
+			whereVAL.Expr = &whereLogical{op: whereDollar[2].String, left: whereDollar[1].Expr, right: whereDollar[3].Expr}
+		}
+	case 8:
+		whereDollar = whereS[wherept-2 : wherept+1]
+		{
+			whereVAL.Expr = &whereNot{expr: whereDollar[2].Expr}
+		}
+	case 10:
+		whereDollar = whereS[wherept-3 : wherept+1]
+		{
+			whereVAL.Expr = &whereCompare{op: whereDollar[2].String, left: whereDollar[1].Expr, right: whereDollar[3].Expr}
+		}
+	case 19:
+		whereDollar = whereS[wherept-1 : wherept+1]
+		{
+			whereVAL.Expr = &whereField{name: whereDollar[1].String}
+		}
+	case 20:
+		whereDollar = whereS[wherept-1 : wherept+1]
+		{
+			whereVAL.Expr = &whereLiteral{value: whereValue{kind: whereString, str: whereDollar[1].String}}
+		}
+	case 21:
+		whereDollar = whereS[wherept-1 : wherept+1]
+		{
+			whereVAL.Expr = &whereLiteral{value: whereValue{kind: whereNumber, num: whereDollar[1].Number}}
+		}
+	case 22:
+		whereDollar = whereS[wherept-1 : wherept+1]
+		{
+			whereVAL.Expr = &whereLiteral{value: whereValue{kind: whereBool, b: whereDollar[1].Bool}}
+		}
+	case 23:
+		whereDollar = whereS[wherept-3 : wherept+1]
+		{
+			whereVAL.Expr = whereDollar[2].Expr
+		}
+	}
+	goto wherestack /* stack new state and value */
+}
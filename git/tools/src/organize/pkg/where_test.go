@@ -0,0 +1,156 @@
+package organize
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"testing"
+
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		for _, s := range []string{
+			`host == "github.com" && owner =~ "^rancher" && !dirty && remotes > 1`,
+			`(bare || branch != "main") && path !~ ` + "`/vendor/`",
+			`!(remotes <= 0) || name >= "m"`,
+			`dirty == false`,
+		} {
+			filter, err := ParseFilter(s)
+			require.NoError(t, err, s)
+			assert.Equal(t, s, filter.String())
+		}
+	})
+
+	t.Run("SyntaxErrors", func(t *testing.T) {
+		for s, msg := range map[string]string{
+			`host ==`:           "syntax error at column 8: unexpected end of expression",
+			`(host == "a"`:      "syntax error at column 13: unexpected end of expression, expecting ')'",
+			`host == "a" owner`: "syntax error at column 13 near 'owner': unexpected field",
+			`host = "a"`:        "unexpected '=', use '==' to compare values at column 6",
+			`name == "a`:        "unterminated string at column 9",
+			`a $ b`:             "unexpected character '$' at column 3",
+		} {
+			_, err := ParseFilter(s)
+			require.ErrorIs(t, err, ErrInvalidFilter, s)
+			assert.ErrorContains(t, err, msg, s)
+		}
+	})
+
+	t.Run("TypeErrors", func(t *testing.T) {
+		for s, msg := range map[string]string{
+			`hots == "a"`:        "unknown field 'hots', expected one of: bare, branch, dirty, host, name, owner, path, remotes, url",
+			`remotes > "1"`:      `cannot compare remotes (number) with "1" (string)`,
+			`host`:               "expected a boolean, but host is a string",
+			`!remotes`:           "'!' expects a boolean, but remotes is a number",
+			`dirty && 3`:         "'&&' expects booleans, but 3 is a number",
+			`dirty < true`:       "'<' cannot be used with booleans",
+			`owner =~ host`:      "'=~' expects a string on the left and a regular expression string literal on the right",
+			`owner =~ "("`:       "bad regular expression",
+			`remotes !~ "[0-9]"`: "'!~' expects a string on the left",
+		} {
+			_, err := ParseFilter(s)
+			require.ErrorIs(t, err, ErrInvalidFilter, s)
+			assert.ErrorContains(t, err, msg, s)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		assert.NoError(t, Config{Where: "bare"}.Validate())
+		assert.ErrorIs(t, Config{Where: "bare &&"}.Validate(), ErrInvalidFilter)
+	})
+}
+
+func TestFilterMatch(t *testing.T) {
+	tempDir := t.TempDir()
+	repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin, remoteUpstream})
+	bareDir, bare := BareRepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteUpstream})
+
+	cfg := NewDefaultConfig()
+
+	match := func(s string, repoPath string) bool {
+		filter, err := ParseFilter(s)
+		require.NoError(t, err)
+
+		r := repo
+		if repoPath == bareDir {
+			r = bare
+		}
+
+		matched, err := filter.Match(cfg, repoPath, r)
+		require.NoError(t, err)
+
+		return matched
+	}
+
+	assert.True(t, match(`host == "github.com" && owner =~ "^origin" && name == "origin" && remotes > 1`, repoDir))
+	assert.True(t, match(`url == "git@github.com:originuser/origin.git"`, repoDir))
+	assert.True(t, match(`path == "`+repoDir+`" && branch == ""`, repoDir))
+	assert.False(t, match(`remotes > 2 || bare`, repoDir))
+
+	// the untracked readme makes the repo dirty, but bare repos are never dirty
+	assert.True(t, match(`dirty`, repoDir))
+	assert.True(t, match(`bare && !dirty`, bareDir))
+
+	// without the primary remote, the first remote by name is used
+	assert.True(t, match(`owner == "upstreamuser" && remotes == 1`, bareDir))
+	cfg.PrimaryRemote = "upstream"
+	assert.True(t, match(`owner == "upstreamuser"`, repoDir))
+}
+
+func TestWhere(t *testing.T) {
+	t.Run("OrganizePaths", func(t *testing.T) {
+		tempDir := t.TempDir()
+		first, _ := RepoWithRemotes(t, path.Join(tempDir, "first"), []*config.RemoteConfig{remoteOrigin})
+		second, _ := RepoWithRemotes(t, path.Join(tempDir, "second"), []*config.RemoteConfig{{Name: "origin", URLs: remoteMirror.URLs}})
+		notRepo := path.Join(tempDir, "not-a-repo")
+		require.NoError(t, NewOSFilesystem().MkdirAll(notRepo, 0755))
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+		cfg.Unsorted = "unsorted"
+		cfg.Where = `owner == "mirroruser"`
+
+		report := NewOrganizer(WithConfig(cfg)).OrganizePaths(context.Background(), []string{first, second, notRepo})
+		assert.Equal(t, 1, report.Count(StatusOrganized))
+		assert.Equal(t, 2, report.Count(StatusSkipped))
+		assert.DirExists(t, first)
+		assert.DirExists(t, notRepo)
+		assert.DirExists(t, path.Join(cfg.Destination, "mirroruser", "mirror"))
+	})
+
+	t.Run("ListAndForEach", func(t *testing.T) {
+		tempDir := t.TempDir()
+		first, _ := RepoWithRemotes(t, path.Join(tempDir, "first"), []*config.RemoteConfig{remoteOrigin})
+		second, _ := RepoWithRemotes(t, path.Join(tempDir, "second"), []*config.RemoteConfig{{Name: "origin", URLs: remoteMirror.URLs}})
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		report := NewOrganizer(WithConfig(cfg)).OrganizePaths(context.Background(), []string{first, second})
+		require.Equal(t, 2, report.Count(StatusOrganized))
+
+		originPath := path.Join(cfg.Destination, "originuser", "origin")
+		mirrorPath := path.Join(cfg.Destination, "mirroruser", "mirror")
+
+		repoPaths, err := NewOrganizer(WithConfig(cfg)).List(context.Background())
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{originPath, mirrorPath}, repoPaths)
+
+		cfg.Where = `host == "github.com" && url =~ "^https://"`
+
+		repoPaths, err = NewOrganizer(WithConfig(cfg)).List(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []string{mirrorPath}, repoPaths)
+
+		var output bytes.Buffer
+		require.NoError(t, NewOrganizer(WithConfig(cfg)).ForEach(context.Background(), `cat README.md && echo " $ORGANIZE_PATH"`, &output))
+		assert.Equal(t, "==> "+mirrorPath+"\nsapmle repo for testing "+mirrorPath+"\n", output.String())
+
+		err = NewOrganizer(WithConfig(cfg)).ForEach(context.Background(), "false", &output)
+		assert.ErrorContains(t, err, mirrorPath)
+	})
+}
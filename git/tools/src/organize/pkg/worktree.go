@@ -0,0 +1,321 @@
+package organize
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/config"
+)
+
+// readGitdirFile returns the git directory a "gitdir:" file points to, and whether it was written as a
+// relative path. Relative paths are resolved against base rather than the directory containing p, since
+// the file may have been copied away from where it was written.
+func readGitdirFile(fs billy.Filesystem, p string, base string) (string, bool, error) {
+	data, err := util.ReadFile(fs, p)
+	if err != nil {
+		return "", false, err
+	}
+
+	line := strings.TrimSpace(string(data))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return "", false, fmt.Errorf("'%s' is not a valid gitdir file", p)
+	}
+
+	gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if path.IsAbs(gitDir) {
+		return path.Clean(gitDir), false, nil
+	}
+
+	return path.Join(base, gitDir), true, nil
+}
+
+// readAdminGitdir returns the path to a linked worktree's .git file stored in the worktree's admin
+// directory, and whether it was written as a relative path.
+func readAdminGitdir(fs billy.Filesystem, p string, base string) (string, bool, error) {
+	data, err := util.ReadFile(fs, p)
+	if err != nil {
+		return "", false, err
+	}
+
+	dotGit := strings.TrimSpace(string(data))
+	if path.IsAbs(dotGit) {
+		return path.Clean(dotGit), false, nil
+	}
+
+	return path.Join(base, dotGit), true, nil
+}
+
+// formatLink returns target as it should be written in a file found in dir.
+func formatLink(dir string, target string, relative bool) (string, error) {
+	if !relative {
+		return target, nil
+	}
+
+	return filepath.Rel(dir, target)
+}
+
+// writeIfChanged only writes data to p if it differs from the existing contents, so that links which do
+// not need to change are left untouched.
+func writeIfChanged(fs billy.Filesystem, p string, data []byte) error {
+	existing, err := util.ReadFile(fs, p)
+	if err == nil && bytes.Equal(existing, data) {
+		return nil
+	}
+
+	info, err := fs.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(fs, p, data, info.Mode().Perm())
+}
+
+func writeGitdirFile(fs billy.Filesystem, p string, target string, relative bool) error {
+	link, err := formatLink(path.Dir(p), target, relative)
+	if err != nil {
+		return err
+	}
+
+	return writeIfChanged(fs, p, []byte("gitdir: "+link+"\n"))
+}
+
+func writeAdminGitdir(fs billy.Filesystem, p string, dotGit string, relative bool) error {
+	link, err := formatLink(path.Dir(p), dotGit, relative)
+	if err != nil {
+		return err
+	}
+
+	return writeIfChanged(fs, p, []byte(link+"\n"))
+}
+
+// linkedWorktreeName returns the name of the linked worktree at repoPath, or an empty string if repoPath
+// is not a linked worktree.
+func linkedWorktreeName(fs billy.Filesystem, repoPath string) (string, error) {
+	dotGit := path.Join(repoPath, ".git")
+
+	info, err := fs.Stat(dotGit)
+	if err != nil || info.IsDir() {
+		return "", nil
+	}
+
+	gitDir, _, err := readGitdirFile(fs, dotGit, repoPath)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := fs.Stat(path.Join(gitDir, "commondir")); os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return path.Base(gitDir), nil
+}
+
+// relocator rewrites the links between git directories and worktrees for a repository copied from
+// oldPath to newPath, the same way `git worktree repair` does.
+type relocator struct {
+	fs      billy.Filesystem
+	oldPath string
+	newPath string
+
+	// relinkWorktrees points linked worktrees outside of newPath at the new copy.
+	relinkWorktrees bool
+}
+
+// translate returns the new location of p if it was inside the relocated repository.
+func (r relocator) translate(p string) string {
+	rel, err := filepath.Rel(r.oldPath, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return p
+	}
+
+	return path.Join(r.newPath, rel)
+}
+
+// relocateDotGitFile handles a .git file found in dir in the new copy of the repository, as is used for
+// linked worktrees and submodules.
+func (r relocator) relocateDotGitFile(dir string) error {
+	dotGit := path.Join(dir, ".git")
+
+	rel, err := filepath.Rel(r.newPath, dir)
+	if err != nil {
+		return err
+	}
+
+	oldGitDir, relative, err := readGitdirFile(r.fs, dotGit, path.Join(r.oldPath, rel))
+	if err != nil {
+		return err
+	}
+
+	gitDir := r.translate(oldGitDir)
+	if err := writeGitdirFile(r.fs, dotGit, gitDir, relative); err != nil {
+		return fmt.Errorf("could not update gitdir file '%s': %w", dotGit, err)
+	}
+
+	// a git directory which was moved along with the repository may have its own linked worktrees
+	if gitDir != oldGitDir {
+		if err := r.relocateWorktrees(gitDir); err != nil {
+			return err
+		}
+	}
+
+	// the git directory for a linked worktree knows where its worktree lives
+	adminGitdir := path.Join(gitDir, "gitdir")
+	if _, err := r.fs.Stat(path.Join(gitDir, "commondir")); err == nil {
+		_, relative, err := readAdminGitdir(r.fs, adminGitdir, gitDir)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if err := writeAdminGitdir(r.fs, adminGitdir, dotGit, relative); err != nil {
+			return fmt.Errorf("could not update worktree gitdir '%s': %w", adminGitdir, err)
+		}
+	}
+
+	// the git directory for a submodule may point back to its worktree
+	configPath := path.Join(gitDir, "config")
+	data, err := util.ReadFile(r.fs, configPath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	cfg := config.NewConfig()
+	if err := cfg.Unmarshal(data); err != nil {
+		return fmt.Errorf("could not parse config '%s': %w", configPath, err)
+	}
+
+	if !path.IsAbs(cfg.Core.Worktree) {
+		return nil
+	}
+
+	if worktree := r.translate(cfg.Core.Worktree); worktree != cfg.Core.Worktree {
+		cfg.Core.Worktree = worktree
+
+		data, err := cfg.Marshal()
+		if err != nil {
+			return err
+		}
+
+		if err := writeIfChanged(r.fs, configPath, data); err != nil {
+			return fmt.Errorf("could not update config '%s': %w", configPath, err)
+		}
+	}
+
+	return nil
+}
+
+// relocateWorktrees handles any linked worktrees registered in the git directory at gitDir in the new copy
+// of the repository.
+func (r relocator) relocateWorktrees(gitDir string) error {
+	worktrees := path.Join(gitDir, "worktrees")
+
+	entries, err := r.fs.ReadDir(worktrees)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(r.newPath, worktrees)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		admin := path.Join(worktrees, entry.Name())
+		adminGitdir := path.Join(admin, "gitdir")
+
+		oldDotGit, relative, err := readAdminGitdir(r.fs, adminGitdir, path.Join(r.oldPath, rel, entry.Name()))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		dotGit := r.translate(oldDotGit)
+		if err := writeAdminGitdir(r.fs, adminGitdir, dotGit, relative); err != nil {
+			return fmt.Errorf("could not update worktree gitdir '%s': %w", adminGitdir, err)
+		}
+
+		// worktrees outside of the new copy still belong to the original repo unless they are relinked
+		if dotGit == oldDotGit && !r.relinkWorktrees {
+			continue
+		}
+
+		// the worktree may have been removed without being pruned
+		if _, err := r.fs.Stat(dotGit); os.IsNotExist(err) {
+			continue
+		}
+
+		_, relative, err = readGitdirFile(r.fs, dotGit, path.Dir(dotGit))
+		if err != nil {
+			return err
+		}
+
+		if err := writeGitdirFile(r.fs, dotGit, admin, relative); err != nil {
+			return fmt.Errorf("could not update gitdir file '%s': %w", dotGit, err)
+		}
+	}
+
+	return nil
+}
+
+// RelocateGitLinks repairs the links between git directories and worktrees in or pointing to a repository
+// which was copied from oldPath to newPath. This covers linked worktrees and their admin directories, and
+// submodules whose .git is a file. Linked worktrees outside of newPath are only pointed at the new copy if
+// relinkWorktrees is set, otherwise they are left linked to the repository at oldPath.
+func RelocateGitLinks(fs billy.Filesystem, oldPath string, newPath string, relinkWorktrees bool) error {
+	r := relocator{
+		fs:              fs,
+		oldPath:         absPath(fs, oldPath),
+		newPath:         absPath(fs, newPath),
+		relinkWorktrees: relinkWorktrees,
+	}
+	newPath = r.newPath
+
+	// bare repositories are their own git directory, but may still have linked worktrees
+	if _, err := fs.Lstat(path.Join(newPath, ".git")); os.IsNotExist(err) {
+		if _, err := fs.Stat(path.Join(newPath, "HEAD")); err == nil {
+			return r.relocateWorktrees(newPath)
+		}
+	}
+
+	return util.Walk(fs, newPath, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Name() != ".git" {
+			return nil
+		}
+
+		if info.Mode().IsRegular() {
+			return r.relocateDotGitFile(path.Dir(p))
+		}
+
+		if info.IsDir() {
+			if err := r.relocateWorktrees(p); err != nil {
+				return err
+			}
+
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
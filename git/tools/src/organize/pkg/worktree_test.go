@@ -0,0 +1,167 @@
+package organize
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertFileContents(t *testing.T, expected string, p string) {
+	data, err := os.ReadFile(p)
+	require.NoError(t, err)
+	assert.Equal(t, expected, strings.TrimSpace(string(data)))
+}
+
+// linkedWorktree adds a linked worktree named name to the repo at repoDir, the same way
+// `git worktree add` would.
+func linkedWorktree(t *testing.T, repoDir string, parent string, name string) string {
+	worktreeDir := path.Join(parent, name)
+	require.NoError(t, os.MkdirAll(worktreeDir, 0755))
+
+	admin := path.Join(repoDir, "storage", "worktrees", name)
+	require.NoError(t, os.MkdirAll(admin, 0755))
+
+	require.NoError(t, os.WriteFile(path.Join(admin, "gitdir"), []byte(path.Join(worktreeDir, ".git")+"\n"), 0644))
+	require.NoError(t, os.WriteFile(path.Join(admin, "commondir"), []byte("../..\n"), 0644))
+	require.NoError(t, os.WriteFile(path.Join(admin, "HEAD"), []byte("ref: refs/heads/"+name+"\n"), 0644))
+	require.NoError(t, os.WriteFile(path.Join(worktreeDir, ".git"), []byte("gitdir: "+admin+"\n"), 0644))
+
+	return worktreeDir
+}
+
+func TestOrganizeWorktrees(t *testing.T) {
+	t.Run("LinkedWorktree", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+		worktreeDir := linkedWorktree(t, repoDir, tempDir, "feature")
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+
+		admin := path.Join(repoDir, "storage", "worktrees", "feature")
+		newAdmin := path.Join(config.Destination, "originuser", "origin", "storage", "worktrees", "feature")
+
+		// the original worktree is left linked to the original repo
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		assertFileContents(t, "gitdir: "+admin, path.Join(worktreeDir, ".git"))
+		assertFileContents(t, path.Join(worktreeDir, ".git"), path.Join(admin, "gitdir"))
+		assertFileContents(t, path.Join(worktreeDir, ".git"), path.Join(newAdmin, "gitdir"))
+
+		worktree, err := git.PlainOpenWithOptions(worktreeDir, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+		require.NoError(t, err)
+
+		newWorktreeDir := path.Join(config.Destination, "originuser", "origin@feature")
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, worktreeDir, worktree))
+		assertFileContents(t, "gitdir: "+admin, path.Join(newWorktreeDir, ".git"))
+		assertFileContents(t, path.Join(newWorktreeDir, ".git"), path.Join(admin, "gitdir"))
+	})
+
+	t.Run("RelinkWorktrees", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+		worktreeDir := linkedWorktree(t, repoDir, tempDir, "feature")
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+		config.RelinkWorktrees = true
+
+		newRepoDir := path.Join(config.Destination, "originuser", "origin")
+		newAdmin := path.Join(newRepoDir, "storage", "worktrees", "feature")
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		assertFileContents(t, "gitdir: "+newAdmin, path.Join(worktreeDir, ".git"))
+		assertFileContents(t, path.Join(worktreeDir, ".git"), path.Join(newAdmin, "gitdir"))
+
+		worktree, err := git.PlainOpenWithOptions(worktreeDir, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+		require.NoError(t, err)
+
+		newWorktreeDir := path.Join(config.Destination, "originuser", "origin@feature")
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, worktreeDir, worktree))
+		assertFileContents(t, "gitdir: "+newAdmin, path.Join(newWorktreeDir, ".git"))
+		assertFileContents(t, path.Join(newWorktreeDir, ".git"), path.Join(newAdmin, "gitdir"))
+	})
+
+	t.Run("LinkedWorktreeFirst", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+		worktreeDir := linkedWorktree(t, repoDir, tempDir, "feature")
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+		config.RelinkWorktrees = true
+
+		newRepoDir := path.Join(config.Destination, "originuser", "origin")
+		newWorktreeDir := path.Join(config.Destination, "originuser", "origin@feature")
+		newAdmin := path.Join(newRepoDir, "storage", "worktrees", "feature")
+
+		worktree, err := git.PlainOpenWithOptions(worktreeDir, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+		require.NoError(t, err)
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, worktreeDir, worktree))
+		assertFileContents(t, path.Join(newWorktreeDir, ".git"), path.Join(repoDir, "storage", "worktrees", "feature", "gitdir"))
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		assertFileContents(t, "gitdir: "+newAdmin, path.Join(newWorktreeDir, ".git"))
+		assertFileContents(t, path.Join(newWorktreeDir, ".git"), path.Join(newAdmin, "gitdir"))
+	})
+
+	t.Run("RelativeSubmodule", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		modules := path.Join(repoDir, "storage", "modules", "sub")
+		require.NoError(t, os.MkdirAll(modules, 0755))
+		require.NoError(t, os.WriteFile(path.Join(modules, "config"), []byte("[core]\n\tworktree = ../../../sub\n"), 0644))
+		require.NoError(t, os.MkdirAll(path.Join(repoDir, "sub"), 0755))
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "sub", ".git"), []byte("gitdir: ../storage/modules/sub\n"), 0644))
+
+		config := NewDefaultConfig()
+		config.Destination = path.Join(tempDir, "destination")
+
+		newRepoDir := path.Join(config.Destination, "originuser", "origin")
+
+		require.NoError(t, OrganizeRepo(context.Background(), config, repoDir, repo))
+		assertFileContents(t, "gitdir: ../storage/modules/sub", path.Join(newRepoDir, "sub", ".git"))
+		assertFileContents(t, "[core]\n\tworktree = ../../../sub", path.Join(newRepoDir, "storage", "modules", "sub", "config"))
+	})
+
+	t.Run("AbsoluteSubmodule", func(t *testing.T) {
+		tempDir := t.TempDir()
+
+		repoDir, repo := RepoWithRemotes(t, tempDir, []*config.RemoteConfig{remoteOrigin})
+
+		modules := path.Join(repoDir, "storage", "modules", "sub")
+		require.NoError(t, os.MkdirAll(modules, 0755))
+		require.NoError(t, os.WriteFile(path.Join(modules, "config"), []byte("[core]\n\tworktree = "+path.Join(repoDir, "sub")+"\n"), 0644))
+		require.NoError(t, os.MkdirAll(path.Join(repoDir, "sub"), 0755))
+		require.NoError(t, os.WriteFile(path.Join(repoDir, "sub", ".git"), []byte("gitdir: "+modules+"\n"), 0644))
+
+		cfg := NewDefaultConfig()
+		cfg.Destination = path.Join(tempDir, "destination")
+
+		newRepoDir := path.Join(cfg.Destination, "originuser", "origin")
+
+		require.NoError(t, OrganizeRepo(context.Background(), cfg, repoDir, repo))
+		assertFileContents(t, "gitdir: "+path.Join(newRepoDir, "storage", "modules", "sub"), path.Join(newRepoDir, "sub", ".git"))
+
+		data, err := os.ReadFile(path.Join(newRepoDir, "storage", "modules", "sub", "config"))
+		require.NoError(t, err)
+
+		moduleConfig := config.NewConfig()
+		require.NoError(t, moduleConfig.Unmarshal(data))
+		assert.Equal(t, path.Join(newRepoDir, "sub"), moduleConfig.Core.Worktree)
+	})
+}